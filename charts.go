@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// defaultTermWidth is the number of columns assumed when the width of the
+// terminal cannot be found out.
+const defaultTermWidth = 80
+
+// sparkTicks are the characters of a sparkline from the lowest value to the
+// highest one.
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// termWidth returns the number of columns of the terminal of f, falling back to
+// the COLUMNS environment variable and to defaultTermWidth.
+func termWidth(f *os.File) int {
+	if w := ttyWidth(f); w > 0 {
+		return w
+	}
+
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+
+	return defaultTermWidth
+}
+
+// printCharts draws, fitted in width columns, a sparkline of the builds
+// timeline and bar charts of the top users and exit codes of b.
+func printCharts(w io.Writer, b stats.Builds, width int) {
+	if len(b.Timeline) > 0 {
+		var values = make([]uint64, len(b.Timeline))
+		for i, bk := range b.Timeline {
+			values[i] = bk.Num
+		}
+
+		var (
+			first = b.Timeline[0].Start
+			last  = b.Timeline[len(b.Timeline)-1].Start
+			label = fmt.Sprintf("Builds per %s (%s - %s)",
+				b.TimelineGranularity, bucketLabel(first, b.TimelineGranularity), bucketLabel(last, b.TimelineGranularity),
+			)
+		)
+
+		fmt.Fprintf(w, "\n%s\n%s\n", label, sparkline(values, width))
+	}
+
+	var users = b.RankedUsers()
+	if len(users) > 5 {
+		users = users[:5]
+	}
+
+	if len(users) > 0 {
+		var (
+			labels = make([]string, len(users))
+			values = make([]uint64, len(users))
+		)
+
+		for i, u := range users {
+			labels[i], values[i] = u.UserID, u.Num
+		}
+
+		fmt.Fprintln(w, "\nTop 5 users")
+		printBarChart(w, labels, values, width)
+	}
+
+	var codes = b.RankedErrCodes()
+	if len(codes) > 5 {
+		codes = codes[:5]
+	}
+
+	if len(codes) > 0 {
+		var (
+			labels = make([]string, len(codes))
+			values = make([]uint64, len(codes))
+		)
+
+		for i, c := range codes {
+			labels[i], values[i] = strconv.Itoa(int(c.ExitCode)), c.Num
+		}
+
+		fmt.Fprintln(w, "\nTop 5 error exit codes")
+		printBarChart(w, labels, values, width)
+	}
+}
+
+// sparkline returns a sparkline of values with at most width characters;
+// consecutive values are added up when there are more values than characters.
+func sparkline(values []uint64, width int) string {
+	if width < 1 {
+		width = 1
+	}
+
+	if len(values) > width {
+		var (
+			per    = (len(values) + width - 1) / width
+			merged = make([]uint64, 0, width)
+		)
+
+		for i := 0; i < len(values); i += per {
+			var sum uint64
+			for j := i; j < i+per && j < len(values); j++ {
+				sum += values[j]
+			}
+			merged = append(merged, sum)
+		}
+
+		values = merged
+	}
+
+	var max uint64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	var sb strings.Builder
+	for _, v := range values {
+		var i = 0
+		if max > 0 {
+			i = int(v * uint64(len(sparkTicks)-1) / max)
+		}
+		sb.WriteRune(sparkTicks[i])
+	}
+
+	return sb.String()
+}
+
+// printBarChart draws a horizontal bar for each value, with its label on the
+// left and the value on the right, fitted in width columns.
+func printBarChart(w io.Writer, labels []string, values []uint64, width int) {
+	var (
+		labelW int
+		valueW int
+		max    uint64
+	)
+
+	for i, l := range labels {
+		if n := len([]rune(l)); n > labelW {
+			labelW = n
+		}
+
+		if n := len(strconv.FormatUint(values[i], 10)); n > valueW {
+			valueW = n
+		}
+
+		if values[i] > max {
+			max = values[i]
+		}
+	}
+
+	var barW = width - labelW - valueW - 2
+	if barW < 1 {
+		barW = 1
+	}
+
+	for i, l := range labels {
+		var n = 0
+		if max > 0 {
+			n = int(values[i] * uint64(barW) / max)
+		}
+
+		fmt.Fprintf(w, "%-*s %s%s %*d\n",
+			labelW, l, strings.Repeat("█", n), strings.Repeat(" ", barW-n), valueW, values[i],
+		)
+	}
+}
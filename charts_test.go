@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTextCharts(t *testing.T) {
+	for i := range testWindows {
+		var tw = testWindows[i]
+		t.Run(tw.desc, func(t *testing.T) {
+			var rep = testReport(t, tw.from, tw.to)
+			rep.ChartsWidth = 40
+
+			var buf bytes.Buffer
+			require.NoError(t, writeText(&buf, rep))
+			assertGolden(t, "report"+tw.suffix+".txt", buf.Bytes())
+		})
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	var tcases = []struct {
+		desc     string
+		values   []uint64
+		width    int
+		expected string
+	}{
+		{desc: "scaled to the greatest value", values: []uint64{0, 1, 2, 3, 4, 5, 6, 7}, width: 10, expected: "▁▂▃▄▅▆▇█"},
+		{desc: "all zero", values: []uint64{0, 0, 0}, width: 10, expected: "▁▁▁"},
+		{desc: "single bucket", values: []uint64{5}, width: 10, expected: "█"},
+		{desc: "single zero bucket", values: []uint64{0}, width: 10, expected: "▁"},
+		{desc: "no buckets", values: nil, width: 10, expected: ""},
+		{desc: "merged values", values: []uint64{1, 1, 0, 0, 2, 2}, width: 3, expected: "▄▁█"},
+		{desc: "merged values with a shorter last one", values: []uint64{1, 1, 1, 1, 1}, width: 2, expected: "█▅"},
+		{desc: "width less than 1", values: []uint64{1, 2}, width: 0, expected: "█"},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, sparkline(tc.values, tc.width))
+		})
+	}
+}
+
+func TestPrintBarChart(t *testing.T) {
+	var tcases = []struct {
+		desc     string
+		labels   []string
+		values   []uint64
+		width    int
+		expected string
+	}{
+		{
+			desc:     "scaled to the greatest value",
+			labels:   []string{"a", "bb"},
+			values:   []uint64{4, 2},
+			width:    10,
+			expected: "a  █████ 4\nbb ██    2\n",
+		},
+		{
+			desc:     "all zero",
+			labels:   []string{"a", "b"},
+			values:   []uint64{0, 0},
+			width:    8,
+			expected: "a      0\nb      0\n",
+		},
+		{
+			desc:     "narrower than the labels",
+			labels:   []string{"long label"},
+			values:   []uint64{10},
+			width:    5,
+			expected: "long label █ 10\n",
+		},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			printBarChart(&buf, tc.labels, tc.values, tc.width)
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// toolDescription is the explanation of what the tool does shown in its help.
+const toolDescription = `Computes the stats of the builds of the Remote Builder service from the CSV
+file which the service exports: number of builds, success rate, users and
+error exit codes with more builds, builds over time, durations, etc. Only the
+builds whose execution finished inside of the indicated time window are
+considered.`
+
+// defaultCommand is the command run when the first argument isn't a command
+// name.
+const defaultCommand = "summary"
+
+// command is a subcommand of the command line tool.
+type command struct {
+	name string
+	// summary is the one line description shown in the help of the tool.
+	summary string
+	// run parses args, which are the arguments after the command name, and
+	// executes the command.
+	run func(args []string) error
+}
+
+var commands = []command{
+	{name: "summary", summary: "Print the stats report in one of several formats (default command)", run: runSummary},
+	{name: "users", summary: "Print the number of builds and success rate of each user", run: runUsers},
+	{name: "codes", summary: "Print the number of failed builds of each error exit code", run: runCodes},
+	{name: "timeline", summary: "Print the number of builds and success rate per hour, day or week", run: runTimeline},
+	{name: "durations", summary: "Print the execution durations of the builds grouped by a dimension", run: runDurations},
+	{name: "serve", summary: "Serve the stats as JSON over HTTP, reloading the CSV files when they change", run: runServe},
+	{name: "validate", summary: "Check that every record of the CSV has the expected fields and formats", run: runValidate},
+	{name: "generate", summary: "Write synthetic CSV records for load testing and fixtures", run: runGenerate},
+}
+
+// toolName returns the name of the binary for the help messages.
+func toolName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// usage writes the help of the tool to w.
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [arguments]\n\n%s\n\nCommands:\n", toolName(), toolDescription)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+
+	fmt.Fprintf(w, "\nRun '%s help <command>' for the arguments of a command.\n", toolName())
+}
+
+// findCommand returns the command whose name is name.
+func findCommand(name string) (command, error) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, nil
+		}
+	}
+
+	return command{}, fmt.Errorf("Unknown command %q. Run '%s help' for the list of commands", name, toolName())
+}
+
+// newFlagSet returns the flag set of the command name whose help starts with
+// description.
+func newFlagSet(name string, description string) *flag.FlagSet {
+	var fs = flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [arguments]\n\n%s\n\nArguments:\n", toolName(), name, description)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// inputFlags are the command line arguments, shared by the commands which
+// compute the stats, which indicate the CSV files, the time window of their
+// records to consider, the semantic rules which they must satisfy, how the
+// records of the same build are counted and where the progress is saved.
+type inputFlags struct {
+	csv        pathsFlag
+	window     windowFlags
+	rules      rulesFlag
+	dedup      dedupFlags
+	checkpoint checkpointFlags
+}
+
+func (inf *inputFlags) register(fs *flag.FlagSet) {
+	fs.Var(&inf.csv, "c", "CSV file path, glob pattern (e.g. 'exports/2018-*.csv') or - for the standard input. It can be repeated for reading several files as if they were one.")
+	inf.window.register(fs)
+
+	inf.rules = rulesFlag{}
+	fs.Var(inf.rules, "rules", "Semantic rules checked on the records, as a comma separated list of rule=action (e.g. end-before-start=reject,future-time=warn); all sets the action of all the rules. "+rulesUsage+" (default all=ignore)")
+
+	inf.dedup.register(fs)
+	inf.checkpoint.register(fs)
+}
+
+// rulesUsage describes the semantic rules and the actions of the -rules
+// argument.
+var rulesUsage = "The rules are: " + strings.Join(ruleNames(stats.SemanticRules), ", ") +
+	". The actions are: ignore, warn (report the record but use it) and reject (report the record and discard it)."
+
+// rulesFlag is a flag.Value of the actions of the semantic rules, as a comma
+// separated list of rule=action. It can be repeated.
+type rulesFlag map[stats.Rule]stats.Action
+
+func (rf rulesFlag) String() string {
+	var items []string
+	for _, r := range stats.SemanticRules {
+		if a, ok := rf[r]; ok {
+			items = append(items, r.String()+"="+a.String())
+		}
+	}
+
+	return strings.Join(items, ",")
+}
+
+func (rf rulesFlag) Set(v string) error {
+	for _, item := range strings.Split(v, ",") {
+		var name, action, ok = strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("Invalid rule action %q. It must be rule=action", item)
+		}
+
+		var a, err = stats.ParseAction(action)
+		if err != nil {
+			return err
+		}
+
+		if name == "all" {
+			for _, r := range stats.SemanticRules {
+				rf[r] = a
+			}
+
+			continue
+		}
+
+		r, err := stats.ParseRule(name)
+		if err != nil || !isSemanticRule(r) {
+			return fmt.Errorf("Invalid rule %q. Valid ones are: all, %s", name, strings.Join(ruleNames(stats.SemanticRules), ", "))
+		}
+
+		rf[r] = a
+	}
+
+	return nil
+}
+
+// dedupFlags are the command line arguments which configure the deduplication
+// of the records by build ID.
+type dedupFlags struct {
+	policy   string
+	expected uint64
+	fpRate   float64
+}
+
+func (df *dedupFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&df.policy, "dedup", "none", "Count only one of the records with the same build ID, the first one (first), the last one (last) or the first one failing if another one has different fields (error)")
+	fs.Uint64Var(&df.expected, "dedup-expected", 0, "Expected number of builds for bounding the memory of -dedup first with a Bloom filter, which may drop a unique build with -dedup-fp-rate probability (default 0, exact)")
+	fs.Float64Var(&df.fpRate, "dedup-fp-rate", stats.DefaultFalsePositiveRate, "Probability of dropping a unique build with -dedup-expected")
+}
+
+// options returns the deduplication options of the arguments.
+func (df dedupFlags) options() (stats.DedupOptions, error) {
+	var p, err = stats.ParseDedupPolicy(df.policy)
+	if err != nil {
+		return stats.DedupOptions{}, err
+	}
+
+	var opts = stats.DedupOptions{Policy: p, ExpectedBuilds: df.expected, FalsePositiveRate: df.fpRate}
+	if p != stats.DedupNone {
+		if _, err := stats.NewDeduplicator(opts); err != nil {
+			return stats.DedupOptions{}, err
+		}
+	}
+
+	return opts, nil
+}
+
+// checkpointFlags are the command line arguments which indicate where the
+// progress of the stats computation is saved and if it's resumed from there.
+type checkpointFlags struct {
+	path     string
+	interval uint64
+	resume   bool
+}
+
+func (cf *checkpointFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&cf.path, "checkpoint", "", "File path where to save periodically the progress of the stats computation, and once more when it's interrupted")
+	fs.Uint64Var(&cf.interval, "checkpoint-interval", stats.DefaultCheckpointInterval, "Number of read records between the saves of -checkpoint")
+	fs.BoolVar(&cf.resume, "resume", false, "Resume the stats computation from the file indicated by -checkpoint. The time window is the one of the checkpoint.")
+}
+
+func isSemanticRule(r stats.Rule) bool {
+	for _, sr := range stats.SemanticRules {
+		if r == sr {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ruleNames(rules []stats.Rule) []string {
+	var names = make([]string, len(rules))
+	for i, r := range rules {
+		names[i] = r.String()
+	}
+
+	return names
+}
+
+// input is the CSV source, the time window, the actions of the semantic rules,
+// the deduplication and the checkpoints indicated by the inputFlags.
+type input struct {
+	csv      *csvSource
+	twFrom   time.Time
+	twTo     time.Time
+	twBounds stats.Bounds
+	loc      *time.Location
+	rules    map[stats.Rule]stats.Action
+	dedup    stats.DedupOptions
+	// checkpoint is the file path where the progress is saved every
+	// cpInterval records, when it isn't empty, and resume the checkpoint of
+	// the offset of csv from where the computation continues, if any.
+	checkpoint string
+	cpInterval uint64
+	resume     *stats.Checkpoint
+}
+
+// semantics returns the options for checking the semantic rules of the records
+// of in, whose violations are printed to the standard error if report is true.
+func (in *input) semantics(report bool) stats.SemanticOptions {
+	var so = stats.SemanticOptions{Actions: in.rules}
+	if report {
+		so.Report = func(v stats.Violation) {
+			fmt.Fprintf(os.Stderr, "%s %s: %s: %s\n", violationLabel(v), in.csv.position(v.Line), v.Rule, v.Message)
+		}
+	}
+
+	return so
+}
+
+// violationLabel returns what is done with the record of v.
+func violationLabel(v stats.Violation) string {
+	if v.Action == stats.ActionWarn {
+		return "Warning:"
+	}
+
+	return "Rejected:"
+}
+
+// validate checks the combinations of the arguments which cannot be used
+// together and returns the deduplication options.
+func (inf inputFlags) validate() (stats.DedupOptions, error) {
+	if len(inf.csv) == 0 {
+		return stats.DedupOptions{}, errors.New("CSV file path must be indicated")
+	}
+
+	var dedup, err = inf.dedup.options()
+	if err != nil {
+		return stats.DedupOptions{}, err
+	}
+
+	var cf = inf.checkpoint
+	if cf.path != "" && dedup.Policy != stats.DedupNone {
+		return stats.DedupOptions{}, errors.New("-dedup cannot be used with -checkpoint")
+	}
+
+	if cf.path != "" && (stats.SemanticOptions{Actions: inf.rules}).Enabled() {
+		return stats.DedupOptions{}, errors.New("-rules cannot be used with -checkpoint")
+	}
+
+	if cf.interval == 0 {
+		return stats.DedupOptions{}, errors.New("-checkpoint-interval must be greater than 0")
+	}
+
+	if cf.resume && cf.path == "" {
+		return stats.DedupOptions{}, errors.New("Checkpoint file path must be indicated for resuming")
+	}
+
+	return dedup, nil
+}
+
+// open validates the arguments, resolves the time window and opens the CSV
+// source, which is positioned at the offset of the checkpoint when it's
+// resumed. The standard input can only be rewound if spool is true.
+func (inf inputFlags) open(spool bool) (*input, error) {
+	var dedup, err = inf.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	from, to, bounds, err := inf.window.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := inf.window.location()
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := openSource(inf.csv, spool)
+	if err != nil {
+		return nil, err
+	}
+
+	var in = &input{
+		csv: src, twFrom: from, twTo: to, twBounds: bounds, loc: loc, rules: inf.rules, dedup: dedup,
+		checkpoint: inf.checkpoint.path, cpInterval: inf.checkpoint.interval,
+	}
+
+	if err := inf.resume(in); err != nil {
+		_ = src.close()
+		return nil, err
+	}
+
+	return in, nil
+}
+
+// resume loads the checkpoint of the arguments, if it's resumed, into in and
+// seeks its CSV source to the checkpoint offset. The time window is the one of
+// the checkpoint, so the one of the arguments, if any, must match it.
+func (inf inputFlags) resume(in *input) error {
+	if in.checkpoint != "" && in.csv.stdin() {
+		return errors.New("Checkpoints cannot be used with the standard input")
+	}
+
+	if !inf.checkpoint.resume {
+		return nil
+	}
+
+	var cp, err = stats.LoadCheckpoint(in.checkpoint)
+	if err != nil {
+		return fmt.Errorf("Error while loading the checkpoint (%s): %s", in.checkpoint, err.Error())
+	}
+
+	var (
+		wf           = inf.window
+		cpFrom, cpTo = cp.State.Window()
+		relative     = wf.last != "" || wf.since != "" || wf.rng != ""
+	)
+
+	if (wf.start != "" && !in.twFrom.Equal(cpFrom)) || (wf.end != "" && !in.twTo.Equal(cpTo)) ||
+		(relative && (!in.twFrom.Equal(cpFrom) || !in.twTo.Equal(cpTo))) ||
+		((wf.bounds != "" || wf.rng != "") && in.twBounds != cp.State.Bounds()) {
+		return errors.New("The time window must be the one of the checkpoint for resuming")
+	}
+
+	if err = in.csv.seek(cp.Offset); err != nil {
+		return fmt.Errorf("Error while seeking the CSV to the checkpoint offset: %s", err.Error())
+	}
+
+	in.twFrom, in.twTo, in.twBounds = cpFrom, cpTo, cp.State.Bounds()
+	in.resume = cp
+	return nil
+}
+
+// computeBuilds computes the stats of in with opts, showing a progress bar when
+// it's appropriated and stopping when the process is interrupted. The
+// checkpoint file of in is removed when the computation completes.
+func computeBuilds(in *input, opts stats.Options) (*stats.Builds, error) {
+	var ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var base int64
+	if in.resume != nil {
+		base = in.resume.Offset
+	}
+
+	var r = csv.NewReader(in.csv)
+	opts.Progress = newProgressBar(in.csv.size())
+	opts.Location = in.loc
+	opts.Bounds = in.twBounds
+	opts.Semantics = in.semantics(true)
+	opts.Dedup = in.dedup
+	opts.Resume = in.resume
+
+	if in.checkpoint != "" {
+		opts.CheckpointInterval = in.cpInterval
+		opts.Checkpoint = func(cp *stats.Checkpoint) error {
+			return stats.SaveCheckpoint(in.checkpoint, cp)
+		}
+	}
+
+	var b, err = stats.ComputeBuildsContext(ctx, r, in.twFrom, in.twTo, opts)
+	if err != nil {
+		return nil, in.csv.annotate(err, r, base)
+	}
+
+	if in.checkpoint != "" {
+		// The computation is complete, so the checkpoint isn't needed anymore.
+		_ = os.Remove(in.checkpoint)
+	}
+
+	return b, nil
+}
+
+// tableFlags are the command line arguments of the commands which print one
+// table.
+type tableFlags struct {
+	inputFlags
+	format string
+}
+
+func (tf *tableFlags) register(fs *flag.FlagSet) {
+	tf.inputFlags.register(fs)
+	fs.StringVar(&tf.format, "format", "text", "Output format, one of: "+strings.Join(sortedNames(tableFormats), ", "))
+}
+
+// parse parses args with fs, which must have tf registered, and opens the input.
+// It returns the function of the selected format.
+func (tf *tableFlags) parse(fs *flag.FlagSet, args []string) (*input, tableFormatFunc, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	var format, ok = tableFormats[tf.format]
+	if !ok {
+		return nil, nil, fmt.Errorf("Invalid output format %q", tf.format)
+	}
+
+	var in, err = tf.open(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return in, format, nil
+}
+
+func runUsers(args []string) error {
+	var (
+		fs  = newFlagSet("users", "Prints the number of builds, failed builds and success rate of each user sorted\nfrom the user with more builds to the one with less.")
+		tf  tableFlags
+		top = fs.Int("top", 0, "Only print this number of users (default all)")
+	)
+
+	tf.register(fs)
+	var in, format, err = tf.parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	b, err := computeBuilds(in, stats.Options{})
+	if err != nil {
+		return err
+	}
+
+	var users = b.RankedUsers()
+	var n = len(users)
+	if *top > 0 && *top < n {
+		n = *top
+	}
+
+	return format(os.Stdout, usersTable("users", "Builds per user", users, n))
+}
+
+func runCodes(args []string) error {
+	var (
+		fs  = newFlagSet("codes", "Prints the number of failed builds of each error exit code sorted from the code\nwith more builds to the one with less.")
+		tf  tableFlags
+		top = fs.Int("top", 0, "Only print this number of exit codes (default all)")
+	)
+
+	tf.register(fs)
+	var in, format, err = tf.parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	b, err := computeBuilds(in, stats.Options{})
+	if err != nil {
+		return err
+	}
+
+	var codes = b.RankedErrCodes()
+	var n = len(codes)
+	if *top > 0 && *top < n {
+		n = *top
+	}
+
+	return format(os.Stdout, exitCodesTable("exit-codes", "Failed builds per exit code", codes, n))
+}
+
+func runTimeline(args []string) error {
+	var (
+		fs   = newFlagSet("timeline", "Prints the number of builds, failed builds and success rate of each hour, day\nor week of the time window. The hours, days and weeks are the ones of -tz.")
+		tf   tableFlags
+		gran = fs.String("granularity", "auto", "Granularity of the timeline, one of: auto, hour, day, week; auto picks one depending on the length of the time window")
+	)
+
+	tf.register(fs)
+	var in, format, err = tf.parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	g, err := stats.ParseGranularity(*gran)
+	if err != nil || g == stats.GranularityNone {
+		return fmt.Errorf("Invalid granularity %q. Valid ones are: auto, hour, day, week", *gran)
+	}
+
+	b, err := computeBuilds(in, stats.Options{Timeline: g})
+	if err != nil {
+		return err
+	}
+
+	return format(os.Stdout, timelineTable(*b))
+}
+
+func runDurations(args []string) error {
+	var (
+		fs  = newFlagSet("durations", "Prints the minimum, mean and maximum execution duration, the number of builds\nand the success rate of the builds grouped by a dimension.")
+		tf  tableFlags
+		dim = fs.String("by", "user", "Dimension to group the builds by, one of: "+strings.Join(stats.DimensionNames, ", "))
+	)
+
+	tf.register(fs)
+	var in, format, err = tf.parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	b, err := computeBuilds(in, stats.Options{GroupBy: *dim})
+	if err != nil {
+		return err
+	}
+
+	return format(os.Stdout, groupsTable(*dim, b.Groups))
+}
+
+// defaultValidateRules are the actions of the semantic rules of the validate
+// command when -rules doesn't set them.
+var defaultValidateRules = rulesFlag{
+	stats.RuleStartBeforeRequest: stats.ActionReject,
+	stats.RuleEndBeforeStart:     stats.ActionReject,
+	stats.RuleNegativeSize:       stats.ActionReject,
+	stats.RuleFutureTime:         stats.ActionWarn,
+	stats.RuleDeletedVocabulary:  stats.ActionWarn,
+}
+
+func runValidate(args []string) error {
+	var (
+		fs    = newFlagSet("validate", "Checks that the records of the CSV have the schema of the Remote Builder service\nexports: the number of fields, RFC 3339 times, boolean deleted flags, integer\nexit codes and image sizes, unique build IDs and the records sorted by request\ntime, and the semantic rules of -rules. It prints a line for each violation and\na summary, and it fails if any record is rejected.")
+		paths pathsFlag
+		rules = rulesFlag{}
+	)
+
+	for r, a := range defaultValidateRules {
+		rules[r] = a
+	}
+
+	fs.Var(&paths, "c", "CSV file path, glob pattern or - for the standard input. It can be repeated.")
+	fs.Var(rules, "rules", "Actions of the semantic rules, as a comma separated list of rule=action (e.g. future-time=reject); all sets the action of all the rules. "+rulesUsage+" (default "+defaultValidateRules.String()+")")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var src, err = openSource(paths, false)
+	if err != nil {
+		return err
+	}
+	defer src.close()
+
+	var r = csv.NewReader(src)
+	s, err := stats.Validate(r, stats.SemanticOptions{Actions: rules}, func(v stats.Violation) {
+		var related, warning string
+		if v.Related > 0 {
+			related = fmt.Sprintf(" (see %s)", src.position(v.Related))
+		}
+
+		if v.Action == stats.ActionWarn {
+			warning = "warning: "
+		}
+
+		fmt.Printf("%s: %s%s: %s%s\n", src.position(v.Line), warning, v.Rule, v.Message, related)
+	})
+	if err != nil {
+		return src.annotate(err, r, 0)
+	}
+
+	if s.Valid() {
+		fmt.Printf("All the %d records are valid\n", s.Records)
+	} else {
+		fmt.Printf("\n%d of the %d records are invalid\n", s.InvalidRecords, s.Records)
+	}
+
+	for _, rl := range stats.Rules {
+		if n := s.Violations[rl]; n > 0 {
+			var warning string
+			if rules[rl] == stats.ActionWarn {
+				warning = " (warning)"
+			}
+
+			fmt.Printf("  %-22s %d%s\n", rl.String()+":", n, warning)
+		}
+	}
+
+	if !s.Valid() {
+		return errors.New("The CSV doesn't have the schema of the Remote Builder service exports")
+	}
+
+	return nil
+}
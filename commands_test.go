@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseSummaryFlags returns the summaryFlags of args.
+func parseSummaryFlags(t *testing.T, args []string) summaryFlags {
+	t.Helper()
+
+	var (
+		fs = flag.NewFlagSet("summary", flag.ContinueOnError)
+		sf summaryFlags
+	)
+
+	fs.SetOutput(io.Discard)
+	sf.register(fs)
+	require.NoError(t, fs.Parse(args))
+	return sf
+}
+
+func TestSummaryFlags_validate(t *testing.T) {
+	var tcases = []struct {
+		desc string
+		args []string
+		// err is a part of the expected error, which is empty when it succeeds.
+		err string
+	}{
+		{desc: "defaults", args: []string{"-c", "builds.csv"}},
+		{desc: "dedup with groups", args: []string{"-c", "builds.csv", "-dedup", "last", "-group-by", "user"}},
+		{desc: "checkpoint with groups", args: []string{"-c", "builds.csv", "-checkpoint", "cp.json", "-resume", "-group-by", "hour"}},
+		{desc: "follow", args: []string{"-c", "builds.csv", "-follow", "-last", "1h"}},
+		{desc: "error: missing CSV", args: nil, err: "CSV file path must be indicated"},
+		{desc: "error: invalid dedup policy", args: []string{"-c", "builds.csv", "-dedup", "any"}, err: "Invalid deduplication policy"},
+		{desc: "error: dedup with checkpoint", args: []string{"-c", "builds.csv", "-dedup", "first", "-checkpoint", "cp.json"}, err: "-dedup cannot be used with -checkpoint"},
+		{desc: "error: dedup with outreach", args: []string{"-c", "builds.csv", "-dedup", "first", "-outreach"}, err: "-dedup cannot be used with -outreach"},
+		{desc: "error: rules with checkpoint", args: []string{"-c", "builds.csv", "-rules", "all=warn", "-checkpoint", "cp.json"}, err: "-rules cannot be used with -checkpoint"},
+		{desc: "error: checkpoint interval 0", args: []string{"-c", "builds.csv", "-checkpoint-interval", "0"}, err: "-checkpoint-interval must be greater than 0"},
+		{desc: "error: resume without checkpoint", args: []string{"-c", "builds.csv", "-resume"}, err: "Checkpoint file path must be indicated for resuming"},
+		{desc: "error: invalid format", args: []string{"-c", "builds.csv", "-format", "xml"}, err: "Invalid output format"},
+		{desc: "error: invalid group-by", args: []string{"-c", "builds.csv", "-group-by", "month"}, err: "Invalid dimension"},
+		{desc: "error: follow with range", args: []string{"-c", "builds.csv", "-follow", "-range", "today"}, err: "-follow can only be used"},
+		{desc: "error: follow with dedup", args: []string{"-c", "builds.csv", "-follow", "-dedup", "first"}, err: "-follow can only be used"},
+		{desc: "error: follow with checkpoint", args: []string{"-c", "builds.csv", "-follow", "-checkpoint", "cp.json"}, err: "-follow can only be used"},
+		{desc: "error: follow with standard input", args: []string{"-c", "-", "-follow"}, err: "-follow requires one CSV file path"},
+		{desc: "error: follow with refresh 0", args: []string{"-c", "builds.csv", "-follow", "-refresh", "0s"}, err: "-refresh must be greater than 0"},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var sf = parseSummaryFlags(t, tc.args)
+			var format, err = sf.validate()
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotNil(t, format)
+		})
+	}
+}
+
+func TestInputFlags_open(t *testing.T) {
+	var (
+		records = []string{sourceRecord("b1", 1), sourceRecord("b2", 2), sourceRecord("b3", 3)}
+		paths   = writeSourceFiles(t, []string{"builds.csv"}, []string{strings.Join(records, "\n") + "\n"})
+		cpPath  = filepath.Join(t.TempDir(), "checkpoint.json")
+		window  = []string{"-s", "2018-10-31T00:00:00Z", "-e", "2018-11-01T00:00:00Z"}
+	)
+
+	// saveCheckpoint saves the checkpoint taken after reading the first record.
+	var saveCheckpoint = func(t *testing.T) {
+		t.Helper()
+
+		var sf = parseSummaryFlags(t, append([]string{"-c", paths[0]}, window...))
+		var in, err = sf.open(false)
+		require.NoError(t, err)
+		defer in.csv.close()
+
+		var saved bool
+		_, err = stats.ComputeBuildsContext(
+			context.Background(), csv.NewReader(in.csv), in.twFrom, in.twTo,
+			stats.Options{CheckpointInterval: 1, Checkpoint: func(cp *stats.Checkpoint) error {
+				if saved {
+					return nil
+				}
+
+				saved = true
+				return stats.SaveCheckpoint(cpPath, cp)
+			}},
+		)
+		require.NoError(t, err)
+		require.True(t, saved)
+	}
+
+	for _, args := range [][]string{window, {"-s", "2018-10-31T00:00:00Z"}, nil} {
+		saveCheckpoint(t)
+
+		var sf = parseSummaryFlags(t, append([]string{"-c", paths[0], "-checkpoint", cpPath, "-resume"}, args...))
+		var in, err = sf.open(false)
+		require.NoError(t, err, "arguments %v", args)
+		require.NotNil(t, in.resume)
+		assert.Equal(t, uint64(1), in.resume.Line)
+		assert.True(t, time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC).Equal(in.twTo))
+
+		b, err := computeBuilds(in, stats.Options{})
+		require.NoError(t, in.csv.close())
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), b.Num)
+
+		// The computation is complete, so the checkpoint isn't needed anymore.
+		_, err = os.Stat(cpPath)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	t.Run("error: resume with another time window", func(t *testing.T) {
+		saveCheckpoint(t)
+
+		var sf = parseSummaryFlags(t, []string{"-c", paths[0], "-checkpoint", cpPath, "-resume", "-last", "1h"})
+		var _, err = sf.open(false)
+		if assert.Error(t, err) {
+			assert.Equal(t, "The time window must be the one of the checkpoint for resuming", err.Error())
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// defaultFollowWindow is the length of the rolling time window of -follow when
+// -last isn't indicated.
+const defaultFollowWindow = 15 * time.Minute
+
+// tailPollInterval is how often a followed file is checked for new content
+// when its end has been reached.
+const tailPollInterval = 250 * time.Millisecond
+
+// clearScreen moves the cursor to the top left corner of the terminal and
+// clears it.
+const clearScreen = "\033[H\033[2J"
+
+// tailer is an io.Reader of the content of a file which, like tail -F, waits
+// for more content when it reaches its end and reopens the file when it's
+// rotated (a new file replaces it) or truncated. Read returns the error of ctx
+// when it's done.
+type tailer struct {
+	ctx  context.Context
+	path string
+	f    *os.File
+	// offset is the number of bytes read from f.
+	offset int64
+	// last is the last byte read, for separating the content of the rotated
+	// file from the new one with a new line when it doesn't end with one.
+	last byte
+}
+
+func newTailer(ctx context.Context, path string) (*tailer, error) {
+	var f, err = os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error while opening the CSV (%s): %s", path, err.Error())
+	}
+
+	return &tailer{ctx: ctx, path: path, f: f}, nil
+}
+
+func (t *tailer) Read(p []byte) (int, error) {
+	for {
+		if t.f == nil {
+			// The file may not exist for a moment while it's rotated.
+			var f, err = os.Open(t.path)
+			if err == nil {
+				t.f = f
+				t.offset = 0
+				continue
+			}
+		} else {
+			var n, err = t.f.Read(p)
+			if n > 0 {
+				t.offset += int64(n)
+				t.last = p[n-1]
+				return n, nil
+			}
+
+			if err != nil && err != io.EOF {
+				return 0, fmt.Errorf("Error while reading the CSV (%s): %s", t.path, err.Error())
+			}
+
+			if t.rotated() {
+				_ = t.f.Close()
+				t.f = nil
+
+				if t.last != 0 && t.last != '\n' && len(p) > 0 {
+					t.last = '\n'
+					p[0] = '\n'
+					return 1, nil
+				}
+
+				continue
+			}
+		}
+
+		select {
+		case <-t.ctx.Done():
+			return 0, t.ctx.Err()
+		case <-time.After(tailPollInterval):
+		}
+	}
+}
+
+// rotated reports if the path of t is a different file than the one which is
+// being read or if it has been truncated. It isn't rotated while the path
+// doesn't exist, so the rest of the content of the current file is read.
+func (t *tailer) rotated() bool {
+	var pfi, err = os.Stat(t.path)
+	if err != nil {
+		return false
+	}
+
+	ffi, err := t.f.Stat()
+	if err != nil {
+		return true
+	}
+
+	return !os.SameFile(pfi, ffi) || pfi.Size() < t.offset
+}
+
+func (t *tailer) Close() error {
+	if t.f == nil {
+		return nil
+	}
+
+	return t.f.Close()
+}
+
+// follow prints, every refresh, the stats of the builds of the CSV of path
+// which finished in the last length of time, while the file grows, until the
+// process is interrupted. On a terminal, each report replaces the previous one,
+// unless they are written as JSON lines.
+func follow(
+	path string, length time.Duration, refresh time.Duration, loc *time.Location,
+	format formatFunc, jsonLines bool, charts bool,
+) error {
+	var ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var t, err = newTailer(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	var (
+		recs = make(chan *stats.Record)
+		errs = make(chan error, 1)
+		rw   = stats.NewRollingWindow(length, time.Now(), stats.GranularityAuto, loc)
+	)
+
+	go func() {
+		var r = csv.NewReader(t)
+		// The records are checked one by one, so an invalid one is skipped.
+		r.FieldsPerRecord = -1
+		for {
+			var csvr, err = r.Read()
+			if err != nil {
+				var perr *csv.ParseError
+				if errors.As(err, &perr) {
+					fmt.Fprintf(os.Stderr, "Skipping an invalid record of the CSV (%s): %s\n", path, err.Error())
+					continue
+				}
+
+				errs <- err
+				return
+			}
+
+			rec, err := stats.NewRecordFromCSV(csvr)
+			if err != nil {
+				var line, _ = r.FieldPos(0)
+				fmt.Fprintf(os.Stderr, "Skipping an invalid record of the CSV (%s) on line %d: %s\n", path, line, err.Error())
+				continue
+			}
+
+			select {
+			case recs <- rec:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		ticker = time.NewTicker(refresh)
+		tty    = isTerminal(os.Stdout)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case rec := <-recs:
+			rw.Add(rec)
+
+		case err := <-errs:
+			return err
+
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-ticker.C:
+			var rep = report{Builds: *rw.Builds(time.Now())}
+			if tty && !jsonLines {
+				fmt.Fprint(os.Stdout, clearScreen)
+				if charts {
+					rep.ChartsWidth = termWidth(os.Stdout)
+				}
+			}
+
+			if err := format(os.Stdout, rep.in(loc)); err != nil {
+				return fmt.Errorf("Error while writing the report: %s", err.Error())
+			}
+		}
+	}
+}
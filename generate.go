@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// sizeUnits are the units accepted by parseSize.
+var sizeUnits = map[string]float64{"": 1, "B": 1, "KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12}
+
+// parseSize parses a number of bytes optionally followed by a decimal unit:
+// B, KB, MB, GB or TB (e.g. 200MB).
+func parseSize(s string) (float64, error) {
+	var i = strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+
+	var v, err = strconv.ParseFloat(s[:i], 64)
+	if unit, ok := sizeUnits[strings.ToUpper(s[i:])]; err == nil && ok {
+		return v * unit, nil
+	}
+
+	return 0, fmt.Errorf("Invalid size %q. It must be a number of bytes optionally followed by a unit: B, KB, MB, GB, TB", s)
+}
+
+// parseSeconds parses a duration with the formats of parseDuration and returns
+// it in seconds.
+func parseSeconds(s string) (float64, error) {
+	var d, err = parseDuration(s)
+	return d.Seconds(), err
+}
+
+// distributionFlag is a flag.Value of a stats.Distribution whose values are
+// parsed by parseValue.
+type distributionFlag struct {
+	raw        string
+	dist       stats.Distribution
+	parseValue func(string) (float64, error)
+}
+
+func (df *distributionFlag) String() string {
+	return df.raw
+}
+
+func (df *distributionFlag) Set(v string) error {
+	var d, err = stats.ParseDistribution(v, df.parseValue)
+	if err != nil {
+		return err
+	}
+
+	df.raw, df.dist = v, d
+	return nil
+}
+
+// exitCodesFlag is a flag.Value of the weights of the exit codes, as a comma
+// separated list of code=weight.
+type exitCodesFlag map[uint8]float64
+
+func (ef exitCodesFlag) String() string {
+	var codes []int
+	for c := range ef {
+		codes = append(codes, int(c))
+	}
+	sort.Ints(codes)
+
+	var items []string
+	for _, c := range codes {
+		items = append(items, fmt.Sprintf("%d=%g", c, ef[uint8(c)]))
+	}
+
+	return strings.Join(items, ",")
+}
+
+func (ef exitCodesFlag) Set(v string) error {
+	for _, item := range strings.Split(v, ",") {
+		var code, weight, ok = strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("Invalid exit code weight %q. It must be code=weight", item)
+		}
+
+		var c, err = stats.ParseExitCode(code)
+		if err != nil {
+			return fmt.Errorf("Invalid exit code %q, it must be an integer between 0 and 255", code)
+		}
+
+		w, err := strconv.ParseFloat(weight, 64)
+		if err != nil || w < 0 {
+			return fmt.Errorf("Invalid weight %q of the exit code %d, it must be a non negative number", weight, c)
+		}
+
+		ef[c] = w
+	}
+
+	return nil
+}
+
+func runGenerate(args []string) error {
+	var (
+		fs        = newFlagSet("generate", "Writes synthetic records of the Remote Builder service exports, sorted by the\nrequest time, for load testing and fixtures. The users' number of builds\nfollows a Zipf distribution and the exit codes, queue waits, durations and\nimage sizes the indicated ones; the same arguments and -seed always generate\nthe same records. The distributions are one of: const:V, uniform:MIN:MAX,\nexp:MEAN, lognormal:MEDIAN:SIGMA.")
+		start     = fs.String("s", "", "Start time & date of the request times. Format must be one of: "+timeFormats)
+		end       = fs.String("e", "", "End time & date, excluded, of the request times. Same formats as -s.")
+		tz        = fs.String("tz", "", "IANA timezone name (e.g. America/New_York) of the times & dates without timezone of -s and -e and the generated times (default UTC)")
+		num       = fs.Uint64("n", 1000, "Number of records")
+		seed      = fs.Int64("seed", 1, "Seed of the pseudo-random generator")
+		users     = fs.Uint64("users", 1000, "Number of users")
+		skew      = fs.Float64("user-skew", 1.2, "Exponent, greater than 1, of the Zipf distribution of the users' builds; the greater, the more builds the most active users have")
+		deleted   = fs.Float64("deleted", 0, "Probability of a build to be deleted")
+		out       = fs.String("o", "", "File path where the records are written (default the standard output)")
+		codes     = exitCodesFlag{}
+		queueWait = distributionFlag{raw: "lognormal:20s:1", parseValue: parseSeconds}
+		duration  = distributionFlag{raw: "lognormal:10m:0.8", parseValue: parseSeconds}
+		size      = distributionFlag{raw: "lognormal:200MB:1", parseValue: parseSize}
+	)
+
+	fs.Var(codes, "exit-codes", "Weights of the exit codes, as a comma separated list of code=weight (default "+exitCodesFlag(stats.DefaultExitCodes).String()+")")
+	fs.Var(&queueWait, "queue-wait", "Distribution of the time between the request and the execution start, with durations as values (e.g. exp:30s)")
+	fs.Var(&duration, "duration", "Distribution of the execution duration, with durations as values (e.g. uniform:1m:1h)")
+	fs.Var(&size, "size", "Distribution of the image size, with bytes as values (e.g. lognormal:500MB:0.5)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *start == "" || *end == "" {
+		return errors.New("The time range must be indicated with -s and -e")
+	}
+
+	var loc, err = windowFlags{tz: *tz}.location()
+	if err != nil {
+		return err
+	}
+
+	if *tz == "" {
+		loc = time.UTC
+	}
+
+	from, err := parseTime(*start, loc)
+	if err != nil {
+		return fmt.Errorf("Invalid start time & date: %s", err.Error())
+	}
+
+	to, err := parseTime(*end, loc)
+	if err != nil {
+		return fmt.Errorf("Invalid end time & date: %s", err.Error())
+	}
+
+	var opts = stats.GeneratorOptions{
+		From:        from,
+		To:          to,
+		Records:     *num,
+		Seed:        *seed,
+		Users:       *users,
+		UserSkew:    *skew,
+		ExitCodes:   codes,
+		DeletedRate: *deleted,
+		Location:    loc,
+	}
+
+	for _, df := range []*distributionFlag{&queueWait, &duration, &size} {
+		if df.dist == nil {
+			if err := df.Set(df.raw); err != nil {
+				return err
+			}
+		}
+	}
+	opts.QueueWait, opts.Duration, opts.ImageSize = queueWait.dist, duration.dist, size.dist
+
+	var w = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("Error while creating the CSV file (%s): %s", *out, err.Error())
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	if _, err := stats.Generate(w, opts); err != nil {
+		return fmt.Errorf("Error while writing the records: %s", err.Error())
+	}
+
+	if *out != "" {
+		return w.Close()
+	}
+
+	return nil
+}
@@ -1,3 +1,13 @@
 module github.com/ifraixedes/go-csv-reader-example
 
-require github.com/stretchr/testify v1.3.0
+go 1.24
+
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/stretchr/testify v1.3.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// The dimensions, in pixels, of the SVG charts of the HTML report.
+const (
+	svgWidth   = 720
+	svgHeight  = 240
+	svgMargin  = 40
+	svgBarGap  = 2
+	svgPieSize = 240
+)
+
+// svgPalette are the colors used for the series of the charts.
+var svgPalette = []string{
+	"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f",
+	"#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac",
+}
+
+var htmlTmpl = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Remote Builder service builds stats</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 760px; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ddd; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { padding: 0.3em 0.8em; border: 1px solid #ddd; text-align: left; }
+th { background: #f4f4f4; }
+svg { display: block; margin: 1em 0; }
+svg text { font-size: 11px; fill: #444; }
+.warning { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Remote Builder service builds stats</h1>
+<table>
+<tr><th>Applied time window</th><td>{{.From}} - {{.To}} ({{.Bounds}})</td></tr>
+<tr><th>Number of builds</th><td>{{.Num}}</td></tr>
+<tr><th>Success rate</th><td>{{.Rate}}</td></tr>
+</table>
+{{if .Warning}}<p class="warning">{{.Warning}}</p>{{end}}
+{{range .Charts}}<h2>{{.Title}}</h2>
+{{.SVG}}
+{{end}}
+{{range .Tables}}<h2>{{.Title}}</h2>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+type htmlChart struct {
+	Title string
+	SVG   template.HTML
+}
+
+type htmlData struct {
+	From    string
+	To      string
+	Bounds  string
+	Num     uint64
+	Rate    string
+	Warning string
+	Charts  []htmlChart
+	Tables  []table
+}
+
+// writeHTML writes rep as a self-contained HTML document, whose charts are
+// inline SVG, so it doesn't depend on any external asset.
+func writeHTML(w io.Writer, rep report) error {
+	var (
+		b   = rep.Builds
+		all = stats.Counts{Num: b.Num, NumFailed: b.NumFailed}
+		d   = htmlData{
+			From:   b.From.Format(time.RFC850),
+			To:     b.To.Format(time.RFC850),
+			Bounds: boundsMsg(b.Bounds),
+			Num:    b.Num,
+			Rate:   "-",
+		}
+	)
+
+	if !b.Empty() {
+		var iv = b.RateSuccessInterval()
+		d.Rate = fmt.Sprintf("%.2f%% (95%% CI %.2f%% - %.2f%%)", b.RateSuccess*100, iv.Lower*100, iv.Upper*100)
+	}
+
+	if b.SmallSample() {
+		d.Warning = fmt.Sprintf("Only %d builds, too few to trust the success rate.", all.Num)
+	}
+
+	if len(b.Timeline) > 0 {
+		var (
+			labels = make([]string, len(b.Timeline))
+			builds = make([]float64, len(b.Timeline))
+			rates  = make([]float64, len(b.Timeline))
+		)
+
+		for i, bk := range b.Timeline {
+			labels[i] = bucketLabel(bk.Start, b.TimelineGranularity)
+			builds[i] = float64(bk.Num)
+			rates[i] = math.NaN()
+			if bk.Num > 0 {
+				rates[i] = float64(bk.Counts.RateSuccess())
+			}
+		}
+
+		var g = b.TimelineGranularity.String()
+		d.Charts = append(d.Charts,
+			htmlChart{Title: "Builds per " + g, SVG: svgColumns(labels, builds)},
+			htmlChart{Title: "Success rate per " + g, SVG: svgLine(labels, rates)},
+		)
+	}
+
+	var users = b.RankedUsers()
+	if len(users) > 0 {
+		var (
+			labels []string
+			values []float64
+		)
+
+		for i := 0; i < 5 && i < len(users); i++ {
+			labels = append(labels, users[i].UserID)
+			values = append(values, float64(users[i].Num))
+		}
+
+		d.Charts = append(d.Charts, htmlChart{Title: "Top 5 users", SVG: svgBars(labels, values)})
+	}
+
+	var codes = b.RankedErrCodes()
+	if len(codes) > 0 {
+		var (
+			labels = make([]string, len(codes))
+			values = make([]float64, len(codes))
+		)
+
+		for i, c := range codes {
+			labels[i] = fmt.Sprintf("exit code %d", c.ExitCode)
+			values[i] = float64(c.Num)
+		}
+
+		d.Charts = append(d.Charts, htmlChart{Title: "Failed builds per exit code", SVG: svgPie(labels, values)})
+	}
+
+	for _, t := range reportTables(rep) {
+		// The summary is already shown at the top and the complete users list
+		// may be too long for a report.
+		if t.Name != "summary" && t.Name != "users" {
+			d.Tables = append(d.Tables, t)
+		}
+	}
+
+	return htmlTmpl.Execute(w, d)
+}
+
+// bucketLabel returns the label of the timeline bucket which starts at s.
+func bucketLabel(s time.Time, g stats.Granularity) string {
+	if g == stats.GranularityHour {
+		return s.Format("Jan 2 15h")
+	}
+
+	return s.Format("Jan 2")
+}
+
+// svgColumns returns an SVG vertical bar chart of values.
+func svgColumns(labels []string, values []float64) template.HTML {
+	var (
+		sb     strings.Builder
+		max    = maxValue(values)
+		plotW  = float64(svgWidth - 2*svgMargin)
+		plotH  = float64(svgHeight - 2*svgMargin)
+		colW   = plotW / float64(len(values))
+		labelE = labelEvery(len(labels))
+	)
+
+	svgOpen(&sb, svgWidth, svgHeight)
+	svgAxes(&sb, max, "%.0f")
+
+	for i, v := range values {
+		var (
+			h = plotH * v / max
+			x = svgMargin + float64(i)*colW
+		)
+
+		fmt.Fprintf(&sb, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %.0f</title></rect>`,
+			x+svgBarGap/2, svgMargin+plotH-h, math.Max(colW-svgBarGap, 1), h, svgPalette[0],
+			template.HTMLEscapeString(labels[i]), v,
+		)
+
+		if i%labelE == 0 {
+			svgXLabel(&sb, x+colW/2, labels[i])
+		}
+	}
+
+	sb.WriteString("</svg>")
+	return template.HTML(sb.String())
+}
+
+// svgLine returns an SVG line chart of ratios, which are between 0 and 1; NaN
+// values are gaps in the line.
+func svgLine(labels []string, ratios []float64) template.HTML {
+	var (
+		sb     strings.Builder
+		plotW  = float64(svgWidth - 2*svgMargin)
+		plotH  = float64(svgHeight - 2*svgMargin)
+		step   = plotW / float64(len(ratios))
+		labelE = labelEvery(len(labels))
+		points []string
+	)
+
+	svgOpen(&sb, svgWidth, svgHeight)
+	svgAxes(&sb, 100, "%.0f%%")
+
+	var flush = func() {
+		if len(points) > 0 {
+			fmt.Fprintf(&sb, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`,
+				strings.Join(points, " "), svgPalette[4],
+			)
+		}
+		points = points[:0]
+	}
+
+	for i, r := range ratios {
+		var x = svgMargin + (float64(i)+0.5)*step
+		if i%labelE == 0 {
+			svgXLabel(&sb, x, labels[i])
+		}
+
+		if math.IsNaN(r) {
+			flush()
+			continue
+		}
+
+		var y = svgMargin + plotH*(1-r)
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="%s"><title>%s: %.2f%%</title></circle>`,
+			x, y, svgPalette[4], template.HTMLEscapeString(labels[i]), r*100,
+		)
+	}
+	flush()
+
+	sb.WriteString("</svg>")
+	return template.HTML(sb.String())
+}
+
+// svgBars returns an SVG horizontal bar chart of values.
+func svgBars(labels []string, values []float64) template.HTML {
+	const (
+		barH   = 24
+		labelW = 200
+	)
+
+	var (
+		sb     strings.Builder
+		max    = maxValue(values)
+		height = len(values)*barH + svgMargin
+		plotW  = float64(svgWidth - labelW - svgMargin)
+	)
+
+	svgOpen(&sb, svgWidth, height)
+
+	for i, v := range values {
+		var (
+			y = svgMargin/2 + i*barH
+			w = plotW * v / max
+		)
+
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" text-anchor="end">%s</text>`,
+			labelW-8, y+barH/2+4, template.HTMLEscapeString(labels[i]),
+		)
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`,
+			labelW, y+svgBarGap, w, barH-2*svgBarGap, svgPalette[i%len(svgPalette)],
+		)
+		fmt.Fprintf(&sb, `<text x="%.1f" y="%d">%.0f</text>`, float64(labelW)+w+6, y+barH/2+4, v)
+	}
+
+	sb.WriteString("</svg>")
+	return template.HTML(sb.String())
+}
+
+// svgPie returns an SVG pie chart of values with a legend.
+func svgPie(labels []string, values []float64) template.HTML {
+	var (
+		sb    strings.Builder
+		total float64
+		r     = float64(svgPieSize)/2 - 10
+		cx    = float64(svgPieSize) / 2
+		cy    = float64(svgPieSize) / 2
+		angle = -math.Pi / 2
+	)
+
+	for _, v := range values {
+		total += v
+	}
+
+	svgOpen(&sb, svgWidth, svgPieSize)
+
+	for i, v := range values {
+		var (
+			color = svgPalette[i%len(svgPalette)]
+			title = fmt.Sprintf("%s: %.0f (%.1f%%)", labels[i], v, v/total*100)
+		)
+
+		if v == total {
+			fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"><title>%s</title></circle>`,
+				cx, cy, r, color, template.HTMLEscapeString(title),
+			)
+		} else {
+			var (
+				end   = angle + 2*math.Pi*v/total
+				large = 0
+			)
+
+			if end-angle > math.Pi {
+				large = 1
+			}
+
+			fmt.Fprintf(&sb, `<path d="M%.1f,%.1f L%.1f,%.1f A%.1f,%.1f 0 %d,1 %.1f,%.1f Z" fill="%s"><title>%s</title></path>`,
+				cx, cy, cx+r*math.Cos(angle), cy+r*math.Sin(angle),
+				r, r, large, cx+r*math.Cos(end), cy+r*math.Sin(end),
+				color, template.HTMLEscapeString(title),
+			)
+			angle = end
+		}
+
+		var ly = 20 + i*20
+		if ly < svgPieSize-10 {
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/><text x="%d" y="%d">%s</text>`,
+				svgPieSize+20, ly-10, color, svgPieSize+38, ly, template.HTMLEscapeString(title),
+			)
+		}
+	}
+
+	sb.WriteString("</svg>")
+	return template.HTML(sb.String())
+}
+
+func svgOpen(sb *strings.Builder, width int, height int) {
+	fmt.Fprintf(sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		width, height, width, height,
+	)
+}
+
+// svgAxes draws the axes of the plot area and the labels of the y axis, from 0
+// to max, formatted with format.
+func svgAxes(sb *strings.Builder, max float64, format string) {
+	var (
+		bottom = svgHeight - svgMargin
+		right  = svgWidth - svgMargin
+	)
+
+	fmt.Fprintf(sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999"/>`, svgMargin, svgMargin, svgMargin, bottom)
+	fmt.Fprintf(sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999"/>`, svgMargin, bottom, right, bottom)
+
+	for i := 0; i <= 4; i++ {
+		var y = float64(bottom) - float64(svgHeight-2*svgMargin)*float64(i)/4
+		fmt.Fprintf(sb, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#eee"/>`, svgMargin+1, y, right, y)
+		fmt.Fprintf(sb, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`,
+			svgMargin-4, y+4, fmt.Sprintf(format, max*float64(i)/4),
+		)
+	}
+}
+
+func svgXLabel(sb *strings.Builder, x float64, label string) {
+	fmt.Fprintf(sb, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`,
+		x, svgHeight-svgMargin+16, template.HTMLEscapeString(label),
+	)
+}
+
+// labelEvery returns every how many items a label of the x axis is drawn for
+// not overlapping them.
+func labelEvery(n int) int {
+	const maxLabels = 8
+
+	if n <= maxLabels {
+		return 1
+	}
+
+	return (n + maxLabels - 1) / maxLabels
+}
+
+// maxValue returns the greatest of values, or 1 if it isn't positive, for
+// using it as the top of the charts.
+func maxValue(values []float64) float64 {
+	var max float64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	if max <= 0 {
+		return 1
+	}
+
+	return max
+}
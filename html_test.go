@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHTML(t *testing.T) {
+	for i := range testWindows {
+		var tw = testWindows[i]
+		t.Run(tw.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeHTML(&buf, testReport(t, tw.from, tw.to)))
+			assertGolden(t, "report"+tw.suffix+".html", buf.Bytes())
+		})
+	}
+
+	t.Run("escaped user IDs", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeHTML(&buf, testReport(t, testWindows[0].from, testWindows[0].to)))
+
+		var out = buf.String()
+		assert.NotContains(t, out, "<b>")
+		// The label of the users chart and the cells of the tables.
+		assert.Contains(t, out, `text-anchor="end">user &#34;B&#34;, &lt;b&gt;&amp;|</text>`)
+		assert.Contains(t, out, `<td>user &#34;B&#34;, &lt;b&gt;&amp;|</td>`)
+	})
+}
+
+func TestMaxValue(t *testing.T) {
+	var tcases = []struct {
+		desc     string
+		values   []float64
+		expected float64
+	}{
+		{desc: "greatest value", values: []float64{3, 7, 5}, expected: 7},
+		{desc: "all zero", values: []float64{0, 0}, expected: 1},
+		{desc: "empty", values: nil, expected: 1},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, maxValue(tc.values))
+		})
+	}
+}
+
+func TestSVGColumns(t *testing.T) {
+	t.Run("all zero", func(t *testing.T) {
+		var svg = string(svgColumns([]string{"a", "b"}, []float64{0, 0}))
+		assert.Equal(t, 2, strings.Count(svg, `height="0.0"`))
+		assert.NotContains(t, svg, "NaN")
+	})
+
+	t.Run("labels of many columns", func(t *testing.T) {
+		var (
+			labels = make([]string, 24)
+			values = make([]float64, 24)
+		)
+		for i := range labels {
+			labels[i] = "l"
+			values[i] = float64(i)
+		}
+
+		var svg = string(svgColumns(labels, values))
+		assert.Equal(t, 24, strings.Count(svg, "<rect"))
+		assert.Equal(t, 8, strings.Count(svg, `text-anchor="middle"`))
+	})
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// jsonVersion is the version of the JSON report schema. It must be increased
+// on any change which isn't backwards compatible, see the README.
+const jsonVersion = 1
+
+// jsonTopN is the number of users and exit codes of the JSON report top lists.
+const jsonTopN = 5
+
+type jsonReport struct {
+	Version      int             `json:"version"`
+	Window       jsonWindow      `json:"window"`
+	Builds       jsonCounts      `json:"builds"`
+	SuccessRate  *jsonRate       `json:"success_rate"`
+	TopUsers     []jsonUser      `json:"top_users"`
+	TopExitCodes []jsonExitCode  `json:"top_exit_codes"`
+	Timeline     *jsonTimeline   `json:"timeline,omitempty"`
+	Groups       *jsonGroups     `json:"groups,omitempty"`
+	Outreach     []jsonFinding   `json:"outreach,omitempty"`
+	Duplicates   *jsonDuplicates `json:"duplicates,omitempty"`
+	Rules        *jsonRules      `json:"rules,omitempty"`
+}
+
+type jsonWindow struct {
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Bounds string    `json:"bounds"`
+}
+
+type jsonCounts struct {
+	Total     uint64 `json:"total"`
+	Succeeded uint64 `json:"succeeded"`
+	Failed    uint64 `json:"failed"`
+}
+
+type jsonRate struct {
+	Rate        float64 `json:"rate"`
+	CI95Lower   float64 `json:"ci95_lower"`
+	CI95Upper   float64 `json:"ci95_upper"`
+	SmallSample bool    `json:"small_sample"`
+}
+
+type jsonUser struct {
+	User        string     `json:"user"`
+	Builds      jsonCounts `json:"builds"`
+	SuccessRate *jsonRate  `json:"success_rate"`
+}
+
+type jsonExitCode struct {
+	ExitCode uint8  `json:"exit_code"`
+	Builds   uint64 `json:"builds"`
+}
+
+type jsonTimeline struct {
+	Granularity string       `json:"granularity"`
+	Buckets     []jsonBucket `json:"buckets"`
+}
+
+type jsonBucket struct {
+	Start  time.Time  `json:"start"`
+	Builds jsonCounts `json:"builds"`
+}
+
+type jsonGroups struct {
+	Dimension string      `json:"dimension"`
+	Groups    []jsonGroup `json:"groups"`
+}
+
+type jsonGroup struct {
+	Key         string        `json:"key"`
+	Builds      jsonCounts    `json:"builds"`
+	SuccessRate *jsonRate     `json:"success_rate"`
+	Durations   jsonDurations `json:"durations"`
+}
+
+type jsonDurations struct {
+	MinSeconds  float64 `json:"min_seconds"`
+	MeanSeconds float64 `json:"mean_seconds"`
+	MaxSeconds  float64 `json:"max_seconds"`
+}
+
+type jsonDuplicates struct {
+	Policy  string `json:"policy"`
+	Dropped uint64 `json:"dropped"`
+}
+
+type jsonRules struct {
+	Rejected   uint64            `json:"rejected"`
+	Violations map[string]uint64 `json:"violations"`
+}
+
+type jsonFinding struct {
+	User     string    `json:"user"`
+	Kind     string    `json:"kind"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	BuildIDs []string  `json:"build_ids"`
+}
+
+func writeJSON(w io.Writer, rep report) error {
+	var enc = json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONReport(rep, jsonTopN))
+}
+
+// writeJSONLine is like writeJSON but it writes the report in one line, for
+// writing several ones as JSON lines.
+func writeJSONLine(w io.Writer, rep report) error {
+	return json.NewEncoder(w).Encode(newJSONReport(rep, jsonTopN))
+}
+
+// newJSONReport returns the JSON representation of rep whose top lists have top
+// users and exit codes, or all of them if it's 0.
+func newJSONReport(rep report, top int) jsonReport {
+	var (
+		b  = rep.Builds
+		jr = jsonReport{
+			Version:      jsonVersion,
+			Window:       jsonWindow{From: b.From, To: b.To, Bounds: b.Bounds.String()},
+			Builds:       newJSONCounts(stats.Counts{Num: b.Num, NumFailed: b.NumFailed}),
+			SuccessRate:  newJSONRate(stats.Counts{Num: b.Num, NumFailed: b.NumFailed}),
+			TopUsers:     []jsonUser{},
+			TopExitCodes: []jsonExitCode{},
+		}
+	)
+
+	for i, u := range b.RankedUsers() {
+		if top > 0 && i == top {
+			break
+		}
+
+		jr.TopUsers = append(jr.TopUsers, jsonUser{
+			User:        u.UserID,
+			Builds:      newJSONCounts(u.Counts),
+			SuccessRate: newJSONRate(u.Counts),
+		})
+	}
+
+	for i, c := range b.RankedErrCodes() {
+		if top > 0 && i == top {
+			break
+		}
+
+		jr.TopExitCodes = append(jr.TopExitCodes, jsonExitCode{ExitCode: c.ExitCode, Builds: c.Num})
+	}
+
+	if b.TimelineGranularity != stats.GranularityNone {
+		jr.Timeline = &jsonTimeline{Granularity: b.TimelineGranularity.String(), Buckets: []jsonBucket{}}
+		for _, bk := range b.Timeline {
+			jr.Timeline.Buckets = append(jr.Timeline.Buckets, jsonBucket{
+				Start:  bk.Start,
+				Builds: newJSONCounts(bk.Counts),
+			})
+		}
+	}
+
+	if rep.GroupBy != "" {
+		jr.Groups = &jsonGroups{Dimension: rep.GroupBy, Groups: []jsonGroup{}}
+		for _, g := range rep.Groups {
+			jr.Groups.Groups = append(jr.Groups.Groups, jsonGroup{
+				Key:         g.Key,
+				Builds:      newJSONCounts(g.Counts),
+				SuccessRate: newJSONRate(g.Counts),
+				Durations: jsonDurations{
+					MinSeconds:  g.Durations.Min.Seconds(),
+					MeanSeconds: g.Durations.Mean().Seconds(),
+					MaxSeconds:  g.Durations.Max.Seconds(),
+				},
+			})
+		}
+	}
+
+	if rep.Outreach {
+		jr.Outreach = []jsonFinding{}
+		for _, f := range rep.Findings {
+			jr.Outreach = append(jr.Outreach, jsonFinding{
+				User:     f.UserID,
+				Kind:     f.Kind.String(),
+				Start:    f.Start,
+				End:      f.End,
+				BuildIDs: f.BuildIDs,
+			})
+		}
+	}
+
+	if rep.Dedup != stats.DedupNone {
+		jr.Duplicates = &jsonDuplicates{Policy: rep.Dedup.String(), Dropped: b.NumDuplicates}
+	}
+
+	if b.Violations != nil {
+		jr.Rules = &jsonRules{Rejected: b.NumRejected, Violations: map[string]uint64{}}
+		for r, n := range b.Violations {
+			jr.Rules.Violations[r.String()] = n
+		}
+	}
+
+	return jr
+}
+
+func newJSONCounts(c stats.Counts) jsonCounts {
+	return jsonCounts{
+		Total:     c.Num,
+		Succeeded: c.Num - c.NumFailed,
+		Failed:    c.NumFailed,
+	}
+}
+
+// newJSONRate returns the success rate of c or nil if c doesn't have builds.
+func newJSONRate(c stats.Counts) *jsonRate {
+	if c.Num == 0 {
+		return nil
+	}
+
+	var iv = c.RateSuccessInterval()
+	return &jsonRate{
+		Rate:        float64(c.Num-c.NumFailed) / float64(c.Num),
+		CI95Lower:   iv.Lower,
+		CI95Upper:   iv.Upper,
+		SmallSample: c.SmallSample(),
+	}
+}
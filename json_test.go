@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJSON(t *testing.T) {
+	for i := range testWindows {
+		var tw = testWindows[i]
+		t.Run(tw.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeJSON(&buf, testReport(t, tw.from, tw.to)))
+			assertGolden(t, "report"+tw.suffix+".json", buf.Bytes())
+
+			var fields map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &fields))
+			assert.Equal(t, "1", string(fields["version"]))
+		})
+	}
+
+	t.Run("optional fields", func(t *testing.T) {
+		// The success rates are null without builds and the groups and the
+		// outreach findings are omitted when they haven't been computed.
+		var rep = testReport(t, testWindows[1].from, testWindows[1].to)
+		rep.GroupBy, rep.Groups, rep.Outreach, rep.Findings = "", nil, false, nil
+
+		var buf bytes.Buffer
+		require.NoError(t, writeJSON(&buf, rep))
+
+		var fields map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &fields))
+		assert.Equal(t, "null", string(fields["success_rate"]))
+		assert.Equal(t, "[]", string(fields["top_users"]))
+		assert.Equal(t, "[]", string(fields["top_exit_codes"]))
+		assert.NotContains(t, fields, "groups")
+		assert.NotContains(t, fields, "outreach")
+	})
+}
@@ -1,78 +1,234 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
+	// The timezones database is embedded for not depending on the one of the
+	// system, which minimal containers don't have.
+	_ "time/tzdata"
 
 	"github.com/ifraixedes/go-csv-reader-example/stats"
 )
 
 func main() {
-	var in, err = parseInput()
-	if err != nil {
-		exit(err)
+	var name, args = defaultCommand, os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "-h", "-help", "--help":
+			usage(os.Stdout)
+			exit(nil)
+		case "help":
+			if len(args) == 1 {
+				usage(os.Stdout)
+				exit(nil)
+			}
+
+			name, args = args[1], []string{"-h"}
+		default:
+			if !strings.HasPrefix(args[0], "-") {
+				name, args = args[0], args[1:]
+			}
+		}
 	}
 
-	var r = csv.NewReader(in.csv)
-	b, err := stats.ComputeBuilds(r, in.twFrom, in.twTo)
+	var cmd, err = findCommand(name)
 	if err != nil {
 		exit(err)
 	}
 
-	printBuilds(*b)
+	exit(cmd.run(args))
+}
+
+// summaryFlags are the command line arguments of the summary command.
+type summaryFlags struct {
+	inputFlags
+	outreach     bool
+	failStreak   int
+	alternations int
+	groupBy      string
+	format       string
+	template     string
+	table        string
+	charts       bool
+	follow       bool
+	refresh      time.Duration
+}
+
+func (sf *summaryFlags) register(fs *flag.FlagSet) {
+	sf.inputFlags.register(fs)
+	fs.BoolVar(&sf.outreach, "outreach", false, "Print the users with failure streaks or flapping builds after the stats")
+	fs.IntVar(&sf.failStreak, "fail-streak", 3, "Minimum consecutive failed builds reported by -outreach (0 disables it)")
+	fs.IntVar(&sf.alternations, "alternations", 4, "Minimum consecutive success/failure changes reported by -outreach (0 disables it)")
+	fs.StringVar(&sf.groupBy, "group-by", "", "Print the stats grouped by one of: "+strings.Join(stats.DimensionNames, ", "))
+	fs.StringVar(&sf.format, "format", "text", "Output format, one of: "+strings.Join(sortedNames(formats), ", "))
+	fs.StringVar(&sf.template, "template", "", "File path of a text/template for rendering the report; it overrides -format")
+	fs.StringVar(&sf.table, "table", "", "Only output the table with this name with the csv, tsv and markdown formats (default all)")
+	fs.BoolVar(&sf.charts, "charts", false, "Draw charts of the timeline, top users and exit codes with the text format when the output is a terminal")
+	fs.BoolVar(&sf.follow, "follow", false, "Keep reading the CSV file as it grows, like tail -F, and print the stats of the builds of the last -last duration (default 15m) every -refresh")
+	fs.DurationVar(&sf.refresh, "refresh", 5*time.Second, "How often the stats are printed with -follow")
 }
 
-type input struct {
-	csv    *os.File
-	twFrom time.Time
-	twTo   time.Time
+// validate checks the combinations of the arguments which cannot be used
+// together and returns the function of the selected output format.
+func (sf summaryFlags) validate() (formatFunc, error) {
+	var dedup, err = sf.inputFlags.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var format, ok = formats[sf.format]
+	if !ok {
+		return nil, fmt.Errorf("Invalid output format %q", sf.format)
+	}
+
+	if sf.template != "" {
+		if format, err = newTemplateFormat(sf.template); err != nil {
+			return nil, fmt.Errorf("Error while parsing the template (%s): %s", sf.template, err.Error())
+		}
+	}
+
+	if sf.groupBy != "" {
+		if _, err := stats.DimensionByName(sf.groupBy); err != nil {
+			return nil, err
+		}
+	}
+
+	if dedup.Policy != stats.DedupNone && sf.outreach {
+		return nil, errors.New("-dedup cannot be used with -outreach")
+	}
+
+	if !sf.follow {
+		return format, nil
+	}
+
+	var wf = sf.window
+	if wf.start != "" || wf.end != "" || wf.since != "" || wf.rng != "" || wf.now != "" || wf.bounds != "" ||
+		len(sf.rules) > 0 || dedup.Policy != stats.DedupNone ||
+		sf.checkpoint.path != "" || sf.checkpoint.resume || sf.groupBy != "" || sf.outreach {
+		return nil, errors.New("-follow can only be used with the -last time window and without -bounds, -rules, -dedup, -checkpoint, -resume, -group-by and -outreach")
+	}
+
+	if len(sf.csv) != 1 || sf.csv[0] == stdinPath {
+		return nil, errors.New("-follow requires one CSV file path")
+	}
+
+	if sf.refresh <= 0 {
+		return nil, errors.New("-refresh must be greater than 0")
+	}
+
+	return format, nil
 }
 
-func parseInput() (*input, error) {
+func runSummary(args []string) error {
 	var (
-		csvfp = flag.String("c", "", "CSV file path")
-		tws   = flag.String("s", (time.Time{}).Format(time.RFC822), "Start time & date of the time window (default any). Format must be RFC822.")
-		twe   = flag.String("e", time.Now().Format(time.RFC822), "End time & date of the time window (default current time). Format must be RFC822.")
+		fs = newFlagSet("summary", "Prints the number of builds, the success rate, the users and error exit codes\nwith more builds and, optionally, the builds grouped by a dimension and the\nusers with failure streaks or flapping builds. It's the default command.")
+		sf summaryFlags
 	)
 
-	flag.Parse()
+	sf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var format, err = sf.validate()
+	if err != nil {
+		return err
+	}
 
-	if *csvfp == "" {
-		exit(errors.New("CSV file path must be indicated"))
+	if sf.follow {
+		return sf.runFollow(format)
 	}
 
-	var from, err = time.Parse(time.RFC822, *tws)
+	// The outreach reads the CSV again.
+	in, err := sf.open(sf.outreach)
 	if err != nil {
-		exit(errors.New("Invalid start time & date format"))
+		return err
 	}
+	defer in.csv.close()
 
-	to, err := time.Parse(time.RFC822, *twe)
+	b, err := computeBuilds(in, stats.Options{Timeline: stats.GranularityAuto, GroupBy: sf.groupBy})
 	if err != nil {
-		exit(errors.New("Invalid end time & date format"))
+		return err
+	}
+
+	return sf.render(os.Stdout, format, in, b)
+}
+
+// runFollow prints the stats of the rolling time window of the CSV file while
+// it grows, see follow.
+func (sf summaryFlags) runFollow(format formatFunc) error {
+	var length = defaultFollowWindow
+	if sf.window.last != "" {
+		var err error
+		if length, err = parseDuration(sf.window.last); err != nil {
+			return err
+		}
 	}
 
-	f, err := os.Open(*csvfp)
+	var loc, err = sf.window.location()
 	if err != nil {
-		perr, ok := err.(*os.PathError)
-		if ok {
-			exit(fmt.Errorf("Error while opening the CSV (%s): %s", perr.Path, perr.Err.Error()))
-		} else {
-			exit(fmt.Errorf("Error while opening the CSV (%s): %s", *csvfp, err.Error()))
+		return err
+	}
+
+	var jsonLines = sf.format == "json" && sf.template == ""
+	if jsonLines {
+		format = writeJSONLine
+	}
+
+	return follow(sf.csv[0], length, sf.refresh, loc, format, jsonLines, sf.charts)
+}
+
+// render writes to w the report of the stats b of in with format, finding
+// before the flaky users of in if they are requested.
+func (sf summaryFlags) render(w *os.File, format formatFunc, in *input, b *stats.Builds) error {
+	var rep = report{Builds: *b, Table: sf.table, Dedup: in.dedup.Policy}
+
+	if sf.charts && isTerminal(w) {
+		rep.ChartsWidth = termWidth(w)
+	}
+
+	if sf.groupBy != "" {
+		rep.GroupBy = sf.groupBy
+		rep.Groups = b.Groups
+	}
+
+	if sf.outreach {
+		if err := in.csv.rewind(); err != nil {
+			return fmt.Errorf("Error while rewinding the CSV: %s", err.Error())
+		}
+
+		var (
+			r     = csv.NewReader(in.csv)
+			flaky = stats.FlakyOptions{
+				MinFailureStreak: sf.failStreak, MinAlternations: sf.alternations, Bounds: in.twBounds,
+				Semantics: in.semantics(false),
+			}
+		)
+
+		f, err := stats.FindFlakyUsers(r, in.twFrom, in.twTo, flaky)
+		if err != nil {
+			return in.csv.annotate(err, r, 0)
 		}
+
+		rep.Outreach = true
+		rep.Findings = f
+	}
+
+	if err := format(w, rep.in(in.loc)); err != nil {
+		return fmt.Errorf("Error while writing the report: %s", err.Error())
 	}
 
-	return &input{
-		csv:    f,
-		twFrom: from,
-		twTo:   to,
-	}, nil
+	return nil
 }
 
-func printBuilds(b stats.Builds) {
+func printBuilds(w io.Writer, b stats.Builds) {
 	var (
 		topUsers    []string
 		topErrCodes []uint8
@@ -102,29 +258,99 @@ func printBuilds(b stats.Builds) {
 		topErrCodesMsg = fmt.Sprintf("%v", topErrCodes)
 	}
 
-	var successRateMsg = ""
-	if b.Num > 0 {
-		successRateMsg = fmt.Sprintf("%.2f%%", b.RateSuccess*100)
+	var usersRateMsg = ""
+	for _, u := range topUsers {
+		usersRateMsg += fmt.Sprintf("\n  %-26s%s", u, rateMsg(b.Users[u]))
 	}
 
-	fmt.Printf(`
+	fmt.Fprintf(w, `
 Remote Builder service builds stats
 ====================================
-Applied time Window:      %s - %s
+Applied time Window:      %s - %s (%s)
 Number of Builds:         %d
 Success rate:             %s
 Top 5 users:              %s
 Top 5 error exit codes:   %s
+Top 5 users success rate: %s
 	`,
-		b.From.Format(time.RFC850), b.To.Format(time.RFC850),
+		b.From.Format(time.RFC850), b.To.Format(time.RFC850), boundsMsg(b.Bounds),
 		b.Num,
-		successRateMsg,
+		rateMsg(stats.Counts{Num: b.Num, NumFailed: b.NumFailed}),
 		topUsersMsg,
 		topErrCodesMsg,
+		usersRateMsg,
+	)
+}
+
+// boundsMsg returns which limits of the time window of b are included in it.
+func boundsMsg(b stats.Bounds) string {
+	if b == stats.BoundsHalfOpen {
+		return "end excluded"
+	}
+
+	return "both included"
+}
+
+// rateMsg returns the success rate of c with its confidence interval and a
+// warning when it's computed from a small sample. It's empty if c doesn't have
+// builds.
+func rateMsg(c stats.Counts) string {
+	if c.Num == 0 {
+		return ""
+	}
+
+	var (
+		iv  = c.RateSuccessInterval()
+		msg = fmt.Sprintf("%.2f%% (95%% CI %.2f%% - %.2f%%)", c.RateSuccess()*100, iv.Lower*100, iv.Upper*100)
 	)
+
+	if c.SmallSample() {
+		msg += fmt.Sprintf(" WARNING: only %d builds, too few to trust the rate", c.Num)
+	}
+
+	return msg
+}
+
+func printGroups(w io.Writer, dim string, groups []stats.Group) {
+	var title = "Builds by " + dim
+	fmt.Fprintf(w, "\n%s\n%s\n", title, strings.Repeat("=", len(title)))
+	fmt.Fprintf(w, "%-26s %8s %10s %10s %10s  %s\n", "Group", "Builds", "Min", "Mean", "Max", "Success rate")
+
+	for _, g := range groups {
+		fmt.Fprintf(w, "%-26s %8d %10s %10s %10s  %s\n",
+			g.Key, g.Counts.Num,
+			g.Durations.Min, g.Durations.Mean().Round(time.Second), g.Durations.Max,
+			rateMsg(g.Counts),
+		)
+	}
+}
+
+func printFindings(w io.Writer, f []stats.Finding) {
+	fmt.Fprintf(w, `
+Users to reach out
+==================
+`)
+
+	if len(f) == 0 {
+		fmt.Fprintln(w, "None")
+		return
+	}
+
+	for _, fd := range f {
+		fmt.Fprintf(w, "%s\t%s\t%s - %s\t%d builds: %s\n",
+			fd.UserID, fd.Kind,
+			fd.Start.Format(time.RFC3339), fd.End.Format(time.RFC3339),
+			len(fd.BuildIDs), strings.Join(fd.BuildIDs, " "),
+		)
+	}
 }
 
 func exit(err error) {
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "\nInterrupted")
+		os.Exit(130)
+	}
+
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "There has been an error.\n%s\n", err.Error())
 		os.Exit(1)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mdCellEscaper escapes the characters which break the cells of the GitHub
+// Flavored Markdown tables.
+var mdCellEscaper = strings.NewReplacer(`|`, `\|`, "\n", " ")
+
+// writeMarkdown writes every table of rep as a GitHub Flavored Markdown table
+// under a heading with its title.
+func writeMarkdown(w io.Writer, rep report) error {
+	var tables, err = selectTables(reportTables(rep), rep.Table)
+	if err != nil {
+		return err
+	}
+
+	return writeMarkdownTables(w, tables)
+}
+
+func writeMarkdownTables(w io.Writer, tables []table) error {
+	var bw = bufio.NewWriter(w)
+	fmt.Fprintln(bw, "## Remote Builder service builds stats")
+
+	for _, t := range tables {
+		fmt.Fprintf(bw, "\n### %s\n\n", t.Title)
+
+		if len(t.Rows) == 0 {
+			fmt.Fprintln(bw, "_None_")
+			continue
+		}
+
+		writeMarkdownRow(bw, t.Header)
+
+		var sep = make([]string, len(t.Header))
+		for i := range sep {
+			sep[i] = "---"
+		}
+		writeMarkdownRow(bw, sep)
+
+		for _, r := range t.Rows {
+			writeMarkdownRow(bw, r)
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeMarkdownRow(w io.Writer, cells []string) {
+	var escaped = make([]string, len(cells))
+	for i, c := range cells {
+		escaped[i] = mdCellEscaper.Replace(c)
+	}
+
+	fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	for i := range testWindows {
+		var tw = testWindows[i]
+		t.Run(tw.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeMarkdown(&buf, testReport(t, tw.from, tw.to)))
+			assertGolden(t, "report"+tw.suffix+".md", buf.Bytes())
+		})
+	}
+
+	t.Run("single table", func(t *testing.T) {
+		var rep = testReport(t, testWindows[0].from, testWindows[0].to)
+		rep.Table = "top-users"
+
+		var buf bytes.Buffer
+		require.NoError(t, writeMarkdown(&buf, rep))
+		assert.Equal(t, "## Remote Builder service builds stats\n\n### Top 5 users\n\n"+
+			"| rank | user | builds | failed | success_rate |\n"+
+			"| --- | --- | --- | --- | --- |\n"+
+			"| 1 | userA | 5 | 3 | 0.4000 |\n"+
+			"| 2 | user \"B\", <b>&\\| | 2 | 1 | 0.5000 |\n"+
+			"| 3 | userC | 1 | 0 | 1.0000 |\n",
+			buf.String(),
+		)
+	})
+}
+
+func TestWriteMarkdownRow(t *testing.T) {
+	var tcases = []struct {
+		desc     string
+		cells    []string
+		expected string
+	}{
+		{desc: "plain", cells: []string{"a", "b"}, expected: "| a | b |\n"},
+		{desc: "pipes", cells: []string{"a|b", "|"}, expected: "| a\\|b | \\| |\n"},
+		{desc: "new lines", cells: []string{"a\nb"}, expected: "| a b |\n"},
+		{desc: "empty cell", cells: []string{"", "b"}, expected: "|  | b |\n"},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			writeMarkdownRow(&buf, tc.cells)
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
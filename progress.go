@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// progressMinSize is the minimum size, in bytes, that a CSV file must have for
+// showing a progress bar while it's processed.
+const progressMinSize = 64 << 20
+
+// progressBarWidth is the number of characters of the bar of the progress bar.
+const progressBarWidth = 30
+
+// newProgressBar returns a stats.ProgressFunc which draws a progress bar in
+// stderr for an input of total bytes, or nil if it's smaller than
+// progressMinSize, it's unknown (negative) or stderr isn't a terminal.
+func newProgressBar(total int64) stats.ProgressFunc {
+	if total < progressMinSize || !isTerminal(os.Stderr) {
+		return nil
+	}
+
+	return func(p stats.Progress) {
+		drawProgressBar(os.Stderr, p, total)
+	}
+}
+
+func drawProgressBar(w io.Writer, p stats.Progress, total int64) {
+	var ratio = float64(p.Bytes) / float64(total)
+	if ratio > 1 {
+		ratio = 1
+	}
+
+	var done = int(ratio * progressBarWidth)
+	fmt.Fprintf(w, "\r[%s%s] %3.0f%% %s/%s %d records, %d matched",
+		strings.Repeat("=", done), strings.Repeat(" ", progressBarWidth-done),
+		ratio*100, stats.FormatSize(p.Bytes), stats.FormatSize(total),
+		p.Records, p.Matched,
+	)
+
+	if p.Bytes >= total {
+		fmt.Fprintln(w)
+	}
+}
+
+// isTerminal reports if f is a character device, which is the case of the
+// terminals.
+func isTerminal(f *os.File) bool {
+	var fi, err = f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// promTopN is the number of users whose builds are exposed as metrics; it's
+// limited for keeping the cardinality of the user label low.
+const promTopN = 5
+
+// promLabelEscaper escapes the label values as the exposition format
+// requires.
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promMetric is a metric family of the exposition format with its samples.
+type promMetric struct {
+	name    string
+	help    string
+	samples []promSample
+}
+
+type promSample struct {
+	labels [][2]string
+	value  float64
+}
+
+// reportMetrics returns the metrics with the data of rep. All of them are
+// gauges because they are computed for a time window, so they aren't
+// monotonic.
+func reportMetrics(rep report) []promMetric {
+	var b = rep.Builds
+
+	var metrics = []promMetric{
+		{
+			name:    "remote_builder_window_start_timestamp_seconds",
+			help:    "Start of the time window of the stats.",
+			samples: []promSample{{value: float64(b.From.Unix())}},
+		},
+		{
+			name:    "remote_builder_window_end_timestamp_seconds",
+			help:    "End of the time window of the stats.",
+			samples: []promSample{{value: float64(b.To.Unix())}},
+		},
+		{
+			name:    "remote_builder_builds",
+			help:    "Number of builds executed in the time window.",
+			samples: []promSample{{value: float64(b.Num)}},
+		},
+		{
+			name:    "remote_builder_builds_failed",
+			help:    "Number of builds executed in the time window which failed.",
+			samples: []promSample{{value: float64(b.NumFailed)}},
+		},
+	}
+
+	if rep.Dedup != stats.DedupNone {
+		metrics = append(metrics, promMetric{
+			name:    "remote_builder_duplicates_dropped",
+			help:    "Number of records of the time window which weren't counted for having the build ID of another one.",
+			samples: []promSample{{value: float64(b.NumDuplicates)}},
+		})
+	}
+
+	if b.Violations != nil {
+		var violations = promMetric{
+			name: "remote_builder_rule_violations",
+			help: "Number of violations of each semantic rule by the records of the time window.",
+		}
+		for _, r := range stats.SemanticRules {
+			violations.samples = append(violations.samples, promSample{
+				labels: [][2]string{{"rule", r.String()}}, value: float64(b.Violations[r]),
+			})
+		}
+
+		metrics = append(metrics,
+			promMetric{
+				name:    "remote_builder_records_rejected",
+				help:    "Number of records of the time window which weren't counted for violating a semantic rule.",
+				samples: []promSample{{value: float64(b.NumRejected)}},
+			},
+			violations,
+		)
+	}
+
+	if !b.Empty() {
+		var iv = b.RateSuccessInterval()
+		metrics = append(metrics,
+			promMetric{
+				name:    "remote_builder_build_success_ratio",
+				help:    "Ratio of succeeded builds executed in the time window.",
+				samples: []promSample{{value: float64(b.Num-b.NumFailed) / float64(b.Num)}},
+			},
+			promMetric{
+				name: "remote_builder_build_success_ratio_ci95",
+				help: "Limits of the Wilson score interval, with a 95% confidence level, of the ratio of succeeded builds.",
+				samples: []promSample{
+					{labels: [][2]string{{"bound", "lower"}}, value: iv.Lower},
+					{labels: [][2]string{{"bound", "upper"}}, value: iv.Upper},
+				},
+			},
+		)
+	}
+
+	var codes = promMetric{
+		name: "remote_builder_builds_failed_by_exit_code",
+		help: "Number of builds executed in the time window which failed with each exit code.",
+	}
+
+	for _, c := range b.RankedErrCodes() {
+		codes.samples = append(codes.samples, promSample{
+			labels: [][2]string{{"exit_code", strconv.Itoa(int(c.ExitCode))}},
+			value:  float64(c.Num),
+		})
+	}
+
+	var (
+		users = promMetric{
+			name: "remote_builder_top_user_builds",
+			help: fmt.Sprintf("Number of builds executed in the time window by each of the top %d users.", promTopN),
+		}
+		usersFailed = promMetric{
+			name: "remote_builder_top_user_builds_failed",
+			help: fmt.Sprintf("Number of builds executed in the time window which failed by each of the top %d users.", promTopN),
+		}
+	)
+
+	for i, u := range b.RankedUsers() {
+		if i == promTopN {
+			break
+		}
+
+		var labels = [][2]string{{"user", u.UserID}}
+		users.samples = append(users.samples, promSample{labels: labels, value: float64(u.Num)})
+		usersFailed.samples = append(usersFailed.samples, promSample{labels: labels, value: float64(u.NumFailed)})
+	}
+
+	return append(metrics, codes, users, usersFailed)
+}
+
+// writeMetrics writes the metrics of rep in the Prometheus text exposition
+// format, and in the OpenMetrics one when openMetrics is true.
+func writeMetrics(w io.Writer, rep report, openMetrics bool) error {
+	var bw = bufio.NewWriter(w)
+
+	for _, m := range reportMetrics(rep) {
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(bw, "# TYPE %s gauge\n", m.name)
+
+		for _, s := range m.samples {
+			bw.WriteString(m.name)
+
+			if len(s.labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					fmt.Fprintf(bw, `%s="%s"`, l[0], promLabelEscaper.Replace(l[1]))
+				}
+				bw.WriteByte('}')
+			}
+
+			fmt.Fprintf(bw, " %s\n", strconv.FormatFloat(s.value, 'f', -1, 64))
+		}
+	}
+
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+
+	return bw.Flush()
+}
+
+func writePrometheus(w io.Writer, rep report) error {
+	return writeMetrics(w, rep, false)
+}
+
+func writeOpenMetrics(w io.Writer, rep report) error {
+	return writeMetrics(w, rep, true)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	for i := range testWindows {
+		var tw = testWindows[i]
+		for _, f := range []struct {
+			ext   string
+			write formatFunc
+		}{
+			{ext: ".prom", write: writePrometheus},
+			{ext: ".openmetrics", write: writeOpenMetrics},
+		} {
+			var f = f
+			t.Run(tw.desc+" in "+f.ext[1:], func(t *testing.T) {
+				var buf bytes.Buffer
+				require.NoError(t, f.write(&buf, testReport(t, tw.from, tw.to)))
+				assertGolden(t, "report"+tw.suffix+f.ext, buf.Bytes())
+			})
+		}
+	}
+
+	t.Run("escaped labels", func(t *testing.T) {
+		var rep = testReport(t, testWindows[0].from, testWindows[0].to)
+		rep.Builds.Users = map[string]stats.Counts{"a\\b\n\"c\"": {Num: 2, NumFailed: 1}}
+
+		var buf bytes.Buffer
+		require.NoError(t, writePrometheus(&buf, rep))
+		assert.Contains(t, buf.String(), "\nremote_builder_top_user_builds{user=\"a\\\\b\\n\\\"c\\\"\"} 2\n")
+		assert.Contains(t, buf.String(), "\nremote_builder_top_user_builds_failed{user=\"a\\\\b\\n\\\"c\\\"\"} 1\n")
+	})
+
+	t.Run("terminator", func(t *testing.T) {
+		for _, tw := range testWindows {
+			var rep = testReport(t, tw.from, tw.to)
+
+			var buf bytes.Buffer
+			require.NoError(t, writeOpenMetrics(&buf, rep))
+			assert.True(t, strings.HasSuffix(buf.String(), "\n# EOF\n"), tw.desc)
+			assert.Equal(t, 1, strings.Count(buf.String(), "# EOF"), tw.desc)
+
+			buf.Reset()
+			require.NoError(t, writePrometheus(&buf, rep))
+			assert.NotContains(t, buf.String(), "# EOF", tw.desc)
+		}
+	})
+
+	t.Run("success ratio of a window without builds", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writePrometheus(&buf, testReport(t, testWindows[1].from, testWindows[1].to)))
+		assert.NotContains(t, buf.String(), "remote_builder_build_success_ratio")
+		assert.Contains(t, buf.String(), "\nremote_builder_builds 0\n")
+	})
+}
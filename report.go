@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// report contains everything that the command line tool has computed and has
+// to output.
+type report struct {
+	Builds stats.Builds
+	// GroupBy is the name of the dimension of Groups; it's empty when the stats
+	// haven't been grouped.
+	GroupBy string
+	Groups  []stats.Group
+	// Outreach indicates if the flaky users have been looked for, so Findings is
+	// meaningful even when it's empty.
+	Outreach bool
+	Findings []stats.Finding
+	// Table restricts the tabular formats to the table with this name.
+	Table string
+	// ChartsWidth is the number of columns where the text format draws the
+	// charts; they aren't drawn when it's 0.
+	ChartsWidth int
+	// Dedup is the policy with which the records with the same build ID have
+	// been deduplicated, so Builds.NumDuplicates is meaningful.
+	Dedup stats.DedupPolicy
+}
+
+// in returns a copy of rep whose times are in loc, so they are printed in such
+// location.
+func (rep report) in(loc *time.Location) report {
+	var b = rep.Builds
+	b.From = b.From.In(loc)
+	b.To = b.To.In(loc)
+	b.Timeline = make([]stats.TimelineBucket, len(rep.Builds.Timeline))
+	for i, bk := range rep.Builds.Timeline {
+		bk.Start = bk.Start.In(loc)
+		b.Timeline[i] = bk
+	}
+
+	var findings = make([]stats.Finding, len(rep.Findings))
+	for i, f := range rep.Findings {
+		f.Start = f.Start.In(loc)
+		f.End = f.End.In(loc)
+		findings[i] = f
+	}
+
+	rep.Builds = b
+	rep.Findings = findings
+	return rep
+}
+
+// formatFunc writes rep to w in some specific format.
+type formatFunc func(w io.Writer, rep report) error
+
+// formats contains the output formats indexed by the name used for selecting
+// them from the command line.
+var formats = map[string]formatFunc{
+	"text": writeText,
+	"json": writeJSON,
+	"csv":  writeCSV,
+	"tsv":  writeTSV,
+	"html": writeHTML,
+
+	"markdown": writeMarkdown,
+
+	"prometheus":  writePrometheus,
+	"openmetrics": writeOpenMetrics,
+}
+
+// sortedNames returns the sorted names of the formats of m.
+func sortedNames[F any](m map[string]F) []string {
+	var names = make([]string, 0, len(m))
+	for n := range m {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func writeText(w io.Writer, rep report) error {
+	printBuilds(w, rep.Builds)
+
+	if rep.Dedup != stats.DedupNone {
+		fmt.Fprintf(w, "\nDuplicated builds dropped: %d (%s policy)\n", rep.Builds.NumDuplicates, rep.Dedup)
+	}
+
+	if rep.Builds.Violations != nil {
+		fmt.Fprintf(w, "\nRecords rejected by rules: %d\n", rep.Builds.NumRejected)
+		for _, r := range stats.SemanticRules {
+			if n := rep.Builds.Violations[r]; n > 0 {
+				fmt.Fprintf(w, "  %-22s %d\n", r.String()+":", n)
+			}
+		}
+	}
+
+	if rep.ChartsWidth > 0 {
+		printCharts(w, rep.Builds, rep.ChartsWidth)
+	}
+
+	if rep.GroupBy != "" {
+		printGroups(w, rep.GroupBy, rep.Groups)
+	}
+
+	if rep.Outreach {
+		printFindings(w, rep.Findings)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "Update the golden files of testdata with the output of the tests")
+
+// testUserB is a user ID with the characters which the output formats have to
+// escape or quote.
+const testUserB = `user "B", <b>&|`
+
+// testRecords returns the records of the report fixture, whose builds finished
+// between 10:01 and 10:08 of 2018-10-31 UTC, encoded as CSV.
+func testRecords(t *testing.T) string {
+	t.Helper()
+
+	var builds = []struct {
+		user     string
+		min      int
+		exitCode int
+	}{
+		{user: "userA", min: 1},
+		{user: "userA", min: 2, exitCode: 1},
+		{user: "userA", min: 3, exitCode: 1},
+		{user: "userA", min: 4, exitCode: 1},
+		{user: "userA", min: 5},
+		{user: testUserB, min: 6},
+		{user: testUserB, min: 7, exitCode: 2},
+		{user: "userC", min: 8},
+	}
+
+	var (
+		buf bytes.Buffer
+		w   = csv.NewWriter(&buf)
+	)
+	for i, b := range builds {
+		var end = time.Date(2018, 10, 31, 10, b.min, 0, 0, time.UTC)
+		require.NoError(t, w.Write([]string{
+			"build" + strconv.Itoa(i+1),
+			b.user,
+			end.Add(-time.Duration(i+2) * 10 * time.Second).Format(time.RFC3339),
+			end.Add(-time.Duration(i+1) * 10 * time.Second).Format(time.RFC3339),
+			end.Format(time.RFC3339),
+			"false",
+			strconv.Itoa(b.exitCode),
+			strconv.Itoa((i + 1) * 1000),
+		}))
+	}
+	w.Flush()
+	require.NoError(t, w.Error())
+
+	return buf.String()
+}
+
+// testReport returns the report, with the timeline, grouped by user and with
+// the outreach findings, of the fixture records of the time window.
+func testReport(t *testing.T, from time.Time, to time.Time) report {
+	t.Helper()
+
+	var in = testRecords(t)
+	var b, err = stats.ComputeBuildsContext(
+		context.Background(), csv.NewReader(bytes.NewReader([]byte(in))), from, to,
+		stats.Options{Timeline: stats.GranularityAuto},
+	)
+	require.NoError(t, err)
+
+	groups, err := stats.ComputeGroups(csv.NewReader(bytes.NewReader([]byte(in))), from, to, stats.ByUser)
+	require.NoError(t, err)
+
+	findings, err := stats.FindFlakyUsers(
+		csv.NewReader(bytes.NewReader([]byte(in))), from, to, stats.FlakyOptions{MinFailureStreak: 3},
+	)
+	require.NoError(t, err)
+
+	return report{Builds: *b, GroupBy: "user", Groups: groups, Outreach: true, Findings: findings}
+}
+
+// testWindows are the time windows of the golden files of the output formats;
+// the empty one doesn't contain any build.
+var testWindows = []struct {
+	desc     string
+	suffix   string
+	from, to time.Time
+}{
+	{
+		desc: "builds",
+		from: time.Date(2018, 10, 31, 10, 0, 0, 0, time.UTC),
+		to:   time.Date(2018, 10, 31, 11, 0, 0, 0, time.UTC),
+	},
+	{
+		desc:   "window without builds",
+		suffix: "_empty",
+		from:   time.Date(2018, 10, 31, 11, 0, 0, 0, time.UTC),
+		to:     time.Date(2018, 10, 31, 12, 0, 0, 0, time.UTC),
+	},
+}
+
+// assertGolden asserts that out is the content of the file name of testdata,
+// which is overwritten with out when the tests run with -update.
+func assertGolden(t *testing.T, name string, out []byte) {
+	t.Helper()
+
+	var golden = filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(golden, out, 0o644))
+	}
+
+	var expected, err = os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(out))
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// dataset contains the records of the CSV files served by the serve command,
+// which are reloaded when the files change.
+type dataset struct {
+	paths []string
+	mu    sync.RWMutex
+	recs  *stats.Records
+	// version identifies the files, by their paths, sizes and modification
+	// times, which recs have been loaded from; failed is the last version which
+	// couldn't be loaded, so it isn't tried again.
+	version  string
+	failed   string
+	loadedAt time.Time
+}
+
+// load reads the records of the files if they have changed since the last
+// time that they were loaded, reporting if they have been reloaded.
+func (ds *dataset) load() (bool, error) {
+	var v, err = filesVersion(ds.paths)
+	if err != nil {
+		return false, err
+	}
+
+	ds.mu.RLock()
+	var same = v == ds.version || v == ds.failed
+	ds.mu.RUnlock()
+	if same {
+		return false, nil
+	}
+
+	recs, err := loadRecords(ds.paths)
+	if err != nil {
+		ds.mu.Lock()
+		ds.failed = v
+		ds.mu.Unlock()
+		return false, err
+	}
+
+	ds.mu.Lock()
+	ds.recs = recs
+	ds.version = v
+	ds.loadedAt = time.Now()
+	ds.mu.Unlock()
+
+	return true, nil
+}
+
+func loadRecords(paths []string) (*stats.Records, error) {
+	var src, err = openSource(paths, false)
+	if err != nil {
+		return nil, err
+	}
+	defer src.close()
+
+	var r = csv.NewReader(src)
+	recs, err := stats.LoadRecords(r)
+	if err != nil {
+		return nil, src.annotate(err, r, 0)
+	}
+
+	return recs, nil
+}
+
+// get returns the records and when they were loaded.
+func (ds *dataset) get() (*stats.Records, time.Time) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.recs, ds.loadedAt
+}
+
+// filesVersion returns an identifier of the current version of the files of
+// paths, which can be glob patterns, so it changes when any of them is
+// modified, added or removed.
+func filesVersion(paths []string) (string, error) {
+	var sb strings.Builder
+	for _, p := range paths {
+		var matches, err = filepath.Glob(p)
+		if err != nil {
+			return "", fmt.Errorf("Invalid CSV file path pattern %q: %s", p, err.Error())
+		}
+
+		if len(matches) == 0 {
+			matches = []string{p}
+		}
+
+		for _, m := range matches {
+			var fi, err = os.Stat(m)
+			if err != nil {
+				return "", fmt.Errorf("Error while opening the CSV (%s): %s", m, err.Error())
+			}
+
+			fmt.Fprintf(&sb, "%s:%d:%d\n", m, fi.Size(), fi.ModTime().UnixNano())
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func runServe(args []string) error {
+	var (
+		fs     = newFlagSet("serve", "Loads the records of the CSV files in memory and serves their stats as JSON over\nHTTP on /builds, which accepts the parameters from and to (the time window,\nwith the same formats as -s and -e), bounds (closed, the default, or half-open\nfor excluding to), top (number of users and exit codes, default 5, 0 for all),\ngranularity (of the timeline, default auto) and by (dimension for grouping the\nbuilds). The files are reloaded when they change.")
+		paths  pathsFlag
+		addr   = fs.String("addr", "localhost:8080", "Address where the HTTP server listens")
+		reload = fs.Duration("reload", 10*time.Second, "How often the files are checked for reloading them when they change (0 disables it)")
+		tz     = fs.String("tz", "", "IANA timezone name (e.g. America/New_York) of the times & dates without timezone of the queries, the hours and days of the timeline and groups, and the returned times & dates (default local)")
+	)
+
+	fs.Var(&paths, "c", "CSV file path or glob pattern (e.g. 'exports/2018-*.csv'). It can be repeated for serving several files as if they were one.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(paths) == 0 {
+		return errors.New("CSV file path must be indicated")
+	}
+
+	for _, p := range paths {
+		if p == stdinPath {
+			return errors.New("The standard input cannot be served")
+		}
+	}
+
+	var loc, err = windowFlags{tz: *tz}.location()
+	if err != nil {
+		return err
+	}
+
+	var ds = &dataset{paths: paths}
+	if _, err := ds.load(); err != nil {
+		return err
+	}
+
+	var ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *reload > 0 {
+		go watchDataset(ctx, ds, *reload)
+	}
+
+	var mux = http.NewServeMux()
+	mux.HandleFunc("GET /builds", func(w http.ResponseWriter, r *http.Request) {
+		serveBuilds(w, r, ds, loc)
+	})
+
+	var srv = &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	var errs = make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	var recs, _ = ds.get()
+	fmt.Fprintf(os.Stderr, "Serving the stats of %d records on http://%s/builds\n", recs.Len(), *addr)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		// The interruption is the way of stopping the server, so a graceful
+		// shutdown is a success.
+		var sctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(sctx); err != nil {
+			return fmt.Errorf("Error while shutting down the server: %s", err.Error())
+		}
+
+		fmt.Fprintln(os.Stderr, "Server stopped")
+		return nil
+	}
+}
+
+// watchDataset reloads ds every interval if its files have changed, until ctx
+// is done. The current records are kept when the reload fails.
+func watchDataset(ctx context.Context, ds *dataset, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var reloaded, err = ds.load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error while reloading the CSV files, the previous records are kept: %s\n", err.Error())
+			continue
+		}
+
+		if reloaded {
+			var recs, _ = ds.get()
+			fmt.Fprintf(os.Stderr, "Reloaded the CSV files with %d records\n", recs.Len())
+		}
+	}
+}
+
+// serveBuilds responds with the JSON report, the one of the json format, of
+// the time window and options of the query parameters of r.
+func serveBuilds(w http.ResponseWriter, r *http.Request, ds *dataset, loc *time.Location) {
+	var (
+		q        = r.URL.Query()
+		from, to = time.Time{}, time.Now()
+		top      = jsonTopN
+		g        = stats.GranularityAuto
+		bounds   = stats.BoundsClosed
+		err      error
+	)
+
+	if v := q.Get("from"); v != "" {
+		if from, err = parseQueryTime(v, loc); err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("Invalid from: %s", err.Error()))
+			return
+		}
+	}
+
+	if v := q.Get("to"); v != "" {
+		if to, err = parseQueryTime(v, loc); err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("Invalid to: %s", err.Error()))
+			return
+		}
+	}
+
+	if v := q.Get("bounds"); v != "" {
+		if bounds, err = stats.ParseBounds(v); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if from.After(to) {
+		httpError(w, http.StatusBadRequest, errors.New("from must be previous or equal to to"))
+		return
+	}
+
+	if v := q.Get("top"); v != "" {
+		if top, err = strconv.Atoi(v); err != nil || top < 0 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("Invalid top %q. It must be a non-negative integer", v))
+			return
+		}
+	}
+
+	if v := q.Get("granularity"); v != "" {
+		if g, err = stats.ParseGranularity(v); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	var dim stats.Dimension
+	var by = q.Get("by")
+	if by != "" {
+		if dim, err = stats.DimensionByName(by); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	var recs, loadedAt = ds.get()
+	var rep = report{Builds: *recs.Builds(from, to, bounds, g, loc)}
+	if dim != nil {
+		rep.GroupBy = by
+		rep.Groups = recs.Groups(from, to, bounds, stats.InLocation(dim, loc))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Last-Modified", loadedAt.UTC().Format(http.TimeFormat))
+	_ = json.NewEncoder(w).Encode(newJSONReport(rep.in(loc), top))
+}
+
+// parseQueryTime is like parseTime, but it also accepts a space instead of the
+// + of the timezone offsets, which is how the query decodes an unencoded one
+// (e.g. from=2018-10-31T10:00:00+02:00).
+func parseQueryTime(s string, loc *time.Location) (time.Time, error) {
+	var t, err = parseTime(s, loc)
+	if err != nil && strings.Contains(s, " ") {
+		if t, perr := parseTime(strings.Replace(s, " ", "+", 1), loc); perr == nil {
+			return t, nil
+		}
+	}
+
+	return t, err
+}
+
+func httpError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
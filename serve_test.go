@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeBuilds(t *testing.T) {
+	var records = []string{
+		sourceRecord("b1", 1),
+		sourceRecord("b2", 2),
+		strings.Replace(sourceRecord("b3", 3), "userA", "userB", 1),
+		strings.Replace(strings.Replace(sourceRecord("b4", 4), "userA", "userC", 1), ",false,1,", ",false,0,", 1),
+	}
+
+	var ds = &dataset{paths: writeSourceFiles(t, []string{"builds.csv"}, []string{strings.Join(records, "\n") + "\n"})}
+	var _, err = ds.load()
+	require.NoError(t, err)
+
+	var tcases = []struct {
+		desc   string
+		query  string
+		status int
+		assert func(t *testing.T, jr jsonReport)
+	}{
+		{
+			desc:   "whole time",
+			query:  "",
+			status: http.StatusOK,
+			assert: func(t *testing.T, jr jsonReport) {
+				assert.Equal(t, jsonCounts{Total: 4, Succeeded: 1, Failed: 3}, jr.Builds)
+				assert.Equal(t, "closed", jr.Window.Bounds)
+				assert.Len(t, jr.TopUsers, 3)
+				assert.Nil(t, jr.Groups)
+			},
+		},
+		{
+			desc:   "time window in the location",
+			query:  "from=2018-10-31T10:00&to=2018-10-31T10:03",
+			status: http.StatusOK,
+			assert: func(t *testing.T, jr jsonReport) {
+				assert.Equal(t, uint64(3), jr.Builds.Total)
+				assert.True(t, time.Date(2018, 10, 31, 10, 3, 0, 0, time.UTC).Equal(jr.Window.To))
+			},
+		},
+		{
+			desc:   "timezone offsets encoded and unencoded",
+			query:  "from=2018-10-31T12:00:00%2B02:00&to=2018-10-31T12:03:00+02:00",
+			status: http.StatusOK,
+			assert: func(t *testing.T, jr jsonReport) {
+				assert.Equal(t, uint64(3), jr.Builds.Total)
+				assert.True(t, time.Date(2018, 10, 31, 10, 3, 0, 0, time.UTC).Equal(jr.Window.To))
+			},
+		},
+		{
+			desc:   "half-open bounds",
+			query:  "from=2018-10-31T10:00&to=2018-10-31T10:03&bounds=half-open",
+			status: http.StatusOK,
+			assert: func(t *testing.T, jr jsonReport) {
+				assert.Equal(t, uint64(2), jr.Builds.Total)
+				assert.Equal(t, "half-open", jr.Window.Bounds)
+			},
+		},
+		{
+			desc:   "top",
+			query:  "top=1",
+			status: http.StatusOK,
+			assert: func(t *testing.T, jr jsonReport) {
+				require.Len(t, jr.TopUsers, 1)
+				assert.Equal(t, "userA", jr.TopUsers[0].User)
+			},
+		},
+		{
+			desc:   "top 0 returns all",
+			query:  "top=0",
+			status: http.StatusOK,
+			assert: func(t *testing.T, jr jsonReport) {
+				assert.Len(t, jr.TopUsers, 3)
+			},
+		},
+		{
+			desc:   "granularity",
+			query:  "from=2018-10-31&to=2018-11-01&granularity=hour",
+			status: http.StatusOK,
+			assert: func(t *testing.T, jr jsonReport) {
+				require.NotNil(t, jr.Timeline)
+				assert.Equal(t, "hour", jr.Timeline.Granularity)
+			},
+		},
+		{
+			desc:   "by",
+			query:  "by=exit-code",
+			status: http.StatusOK,
+			assert: func(t *testing.T, jr jsonReport) {
+				require.NotNil(t, jr.Groups)
+				assert.Equal(t, "exit-code", jr.Groups.Dimension)
+				assert.Len(t, jr.Groups.Groups, 2)
+			},
+		},
+		{desc: "error: invalid from", query: "from=yesterday", status: http.StatusBadRequest},
+		{desc: "error: invalid to", query: "to=2018-13-01", status: http.StatusBadRequest},
+		{desc: "error: invalid to with a space", query: "to=2018-10-31+10:00", status: http.StatusBadRequest},
+		{desc: "error: from after to", query: "from=2018-11-01&to=2018-10-31", status: http.StatusBadRequest},
+		{desc: "error: invalid bounds", query: "bounds=open", status: http.StatusBadRequest},
+		{desc: "error: negative top", query: "top=-1", status: http.StatusBadRequest},
+		{desc: "error: non-integer top", query: "top=all", status: http.StatusBadRequest},
+		{desc: "error: invalid granularity", query: "granularity=month", status: http.StatusBadRequest},
+		{desc: "error: invalid by", query: "by=host", status: http.StatusBadRequest},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var w = httptest.NewRecorder()
+			serveBuilds(w, httptest.NewRequest(http.MethodGet, "/builds?"+tc.query, nil), ds, time.UTC)
+
+			var res = w.Result()
+			defer res.Body.Close()
+			require.Equal(t, tc.status, res.StatusCode)
+			assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+			if tc.assert == nil {
+				var body map[string]string
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+				assert.NotEmpty(t, body["error"])
+				return
+			}
+
+			assert.NotEmpty(t, res.Header.Get("Last-Modified"))
+			var jr jsonReport
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&jr))
+			tc.assert(t, jr)
+		})
+	}
+}
+
+func TestDataset_load(t *testing.T) {
+	var (
+		paths = writeSourceFiles(t, []string{"builds.csv"}, []string{sourceRecord("b1", 1) + "\n"})
+		ds    = &dataset{paths: paths}
+	)
+
+	var reloaded, err = ds.load()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+
+	reloaded, err = ds.load()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "the files haven't changed")
+
+	var recs, loadedAt = ds.get()
+	require.Equal(t, 1, recs.Len())
+
+	t.Run("the previous records are kept when the reload fails", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(paths[0], []byte(sourceRecord("b1", 1)+"\nb2,userA\n"), 0o600))
+
+		var reloaded, err = ds.load()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "builds.csv): record on line 2: wrong number of fields")
+		}
+		assert.False(t, reloaded)
+
+		var r, l = ds.get()
+		assert.True(t, r == recs)
+		assert.Equal(t, loadedAt, l)
+
+		// The failed version isn't loaded again.
+		reloaded, err = ds.load()
+		assert.NoError(t, err)
+		assert.False(t, reloaded)
+
+		var w = httptest.NewRecorder()
+		serveBuilds(w, httptest.NewRequest(http.MethodGet, "/builds", nil), ds, time.UTC)
+		require.Equal(t, http.StatusOK, w.Code)
+		var jr jsonReport
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&jr))
+		assert.Equal(t, uint64(1), jr.Builds.Total)
+	})
+
+	t.Run("the records are reloaded when the files are fixed", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(paths[0], []byte(sourceRecord("b1", 1)+"\n"+sourceRecord("b2", 2)+"\n"), 0o600))
+
+		var reloaded, err = ds.load()
+		require.NoError(t, err)
+		assert.True(t, reloaded)
+
+		var r, _ = ds.get()
+		assert.Equal(t, 2, r.Len())
+	})
+}
+
+func TestRunServe(t *testing.T) {
+	t.Run("interruption stops the server gracefully", func(t *testing.T) {
+		var l, err = net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+		var addr = l.Addr().String()
+		require.NoError(t, l.Close())
+
+		var (
+			paths = writeSourceFiles(t, []string{"builds.csv"}, []string{sourceRecord("b1", 1) + "\n"})
+			errs  = make(chan error, 1)
+		)
+		go func() {
+			errs <- runServe([]string{"-c", paths[0], "-addr", addr, "-reload", "0"})
+		}()
+
+		// The interrupt signal is only handled by the server once it serves.
+		var serving bool
+		for deadline := time.Now().Add(5 * time.Second); !serving && time.Now().Before(deadline); {
+			var res, err = http.Get("http://" + addr + "/builds")
+			if err != nil {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+
+			_ = res.Body.Close()
+			serving = res.StatusCode == http.StatusOK
+		}
+		require.True(t, serving, "the server doesn't serve")
+
+		p, err := os.FindProcess(os.Getpid())
+		require.NoError(t, err)
+		require.NoError(t, p.Signal(os.Interrupt))
+
+		select {
+		case err := <-errs:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the server didn't stop")
+		}
+	})
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// stdinPath is the path which indicates the standard input.
+const stdinPath = "-"
+
+// pathsFlag is a command line argument which can be repeated for indicating
+// several paths.
+type pathsFlag []string
+
+func (pf *pathsFlag) String() string {
+	return strings.Join(*pf, ",")
+}
+
+func (pf *pathsFlag) Set(v string) error {
+	*pf = append(*pf, v)
+	return nil
+}
+
+// csvFile is one of the files of a csvSource.
+type csvFile struct {
+	// name identifies the file in the error messages.
+	name string
+	f    *os.File
+	// r reads the content of f, which is decompressed by dec when it's
+	// compressed.
+	r   io.Reader
+	dec io.ReadCloser
+	// seekable indicates that f is a regular file, so it can be read again.
+	seekable bool
+	// size is the number of bytes that the file contributes to the source,
+	// which includes the new line appended when the file doesn't end with one
+	// (pad); it's -1 if it's unknown because the file isn't a regular one or
+	// it's compressed.
+	size int64
+	pad  bool
+	// read is the number of bytes of the file read so far and lines the number of
+	// lines which they contain, except the skipped ones, which are skippedLines.
+	read         int64
+	lines        int
+	skippedLines int
+	// last is the last byte read from the file.
+	last byte
+	// eof indicates that all the content of the file has been read.
+	eof bool
+	// temp indicates that the file is a temporary copy which must be removed on
+	// close.
+	temp bool
+}
+
+// csvSource is the content of several CSV files, which can be compressed, read
+// one after the other as one stream. A new line is inserted after each file
+// which doesn't end with one, so the last record of a file never joins the
+// first one of the next.
+type csvSource struct {
+	files []*csvFile
+	cur   int
+}
+
+// openSource opens the files of paths, which can be glob patterns or
+// stdinPath. The standard input can only be rewound if spool is true, which
+// copies it to a temporary file before reading it.
+func openSource(paths []string, spool bool) (*csvSource, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("CSV file path must be indicated")
+	}
+
+	var (
+		src   = &csvSource{}
+		stdin bool
+	)
+
+	for _, p := range paths {
+		if p == stdinPath {
+			if stdin {
+				_ = src.close()
+				return nil, errors.New("The standard input can only be indicated once")
+			}
+
+			stdin = true
+			var cf, err = openStdin(spool)
+			if err != nil {
+				_ = src.close()
+				return nil, err
+			}
+
+			src.files = append(src.files, cf)
+			continue
+		}
+
+		var matches, err = filepath.Glob(p)
+		if err != nil {
+			_ = src.close()
+			return nil, fmt.Errorf("Invalid CSV file path pattern %q: %s", p, err.Error())
+		}
+
+		if len(matches) == 0 {
+			// It isn't a pattern or doesn't match any file, so opening it returns
+			// the appropriated error.
+			matches = []string{p}
+		}
+
+		for _, m := range matches {
+			var cf, err = openCSVFile(m)
+			if err != nil {
+				_ = src.close()
+				return nil, err
+			}
+
+			src.files = append(src.files, cf)
+		}
+	}
+
+	return src, nil
+}
+
+func openCSVFile(path string) (*csvFile, error) {
+	var f, err = os.Open(path)
+	if err != nil {
+		perr, ok := err.(*os.PathError)
+		if ok {
+			return nil, fmt.Errorf("Error while opening the CSV (%s): %s", perr.Path, perr.Err.Error())
+		}
+
+		return nil, fmt.Errorf("Error while opening the CSV (%s): %s", path, err.Error())
+	}
+
+	var cf = &csvFile{name: path, f: f}
+	if err := cf.init(); err != nil {
+		_ = cf.close()
+		return nil, fmt.Errorf("Error while opening the CSV (%s): %s", path, err.Error())
+	}
+
+	return cf, nil
+}
+
+func openStdin(spool bool) (*csvFile, error) {
+	if !spool {
+		var cf = &csvFile{name: "stdin", f: os.Stdin}
+		if err := cf.init(); err != nil {
+			return nil, fmt.Errorf("Error while opening the standard input: %s", err.Error())
+		}
+
+		return cf, nil
+	}
+
+	var f, err = os.CreateTemp("", "csv-stdin-*")
+	if err != nil {
+		return nil, fmt.Errorf("Error while copying the standard input: %s", err.Error())
+	}
+
+	var cf = &csvFile{name: "stdin", f: f, temp: true}
+	if _, err := io.Copy(f, os.Stdin); err != nil {
+		_ = cf.close()
+		return nil, fmt.Errorf("Error while copying the standard input: %s", err.Error())
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = cf.close()
+		return nil, fmt.Errorf("Error while copying the standard input: %s", err.Error())
+	}
+
+	if err := cf.init(); err != nil {
+		_ = cf.close()
+		return nil, fmt.Errorf("Error while copying the standard input: %s", err.Error())
+	}
+
+	return cf, nil
+}
+
+// init detects if cf is compressed, sets up its reader and, when it's a regular
+// uncompressed file, its size.
+func (cf *csvFile) init() error {
+	var fi, err = cf.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	cf.size = -1
+	cf.seekable = fi.Mode().IsRegular()
+	if !cf.seekable {
+		// The first bytes cannot be peeked without consuming them, so the
+		// decompress reader always reads it.
+		return cf.decompress()
+	}
+
+	var header = make([]byte, 4)
+	n, err := cf.f.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if stats.DetectCompression(header[:n], cf.name) != stats.CompressionNone {
+		return cf.decompress()
+	}
+
+	cf.r = cf.f
+	cf.size = fi.Size()
+	if cf.size > 0 {
+		var b = make([]byte, 1)
+		if _, err := cf.f.ReadAt(b, cf.size-1); err != nil {
+			return err
+		}
+
+		if b[0] != '\n' {
+			cf.size++
+			cf.pad = true
+		}
+	}
+
+	return nil
+}
+
+// decompress sets up the reader of cf for decompressing it from the current
+// position of f.
+func (cf *csvFile) decompress() error {
+	var dec, _, err = stats.NewDecompressReader(cf.f, cf.name)
+	if err != nil {
+		return err
+	}
+
+	cf.r = dec
+	cf.dec = dec
+	return nil
+}
+
+// reset sets the position of cf to its beginning.
+func (cf *csvFile) reset() error {
+	if !cf.seekable {
+		return errors.New("The standard input cannot be read more than once")
+	}
+
+	if _, err := cf.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if cf.dec != nil {
+		_ = cf.dec.Close()
+		if err := cf.decompress(); err != nil {
+			return err
+		}
+	}
+
+	cf.read = 0
+	cf.lines = 0
+	cf.skippedLines = 0
+	cf.last = 0
+	cf.eof = false
+	return nil
+}
+
+// skip discards n bytes of cf, which must be just reset, returning the number
+// of discarded ones, which is less than n if cf ends before.
+func (cf *csvFile) skip(n int64) (int64, error) {
+	if cf.size < 0 {
+		var d, err = io.CopyN(io.Discard, cf, n)
+		if err != nil && err != io.EOF {
+			return d, err
+		}
+
+		cf.skippedLines, cf.lines = cf.lines, 0
+		return d, nil
+	}
+
+	if n > cf.size {
+		n = cf.size
+	}
+
+	// The appended new line isn't part of the file, so the file position
+	// cannot be beyond its end.
+	var fpos = n
+	if cf.pad && fpos == cf.size {
+		fpos--
+	}
+
+	// The lines of the file are reported from its beginning.
+	var skipped, err = countFileLines(cf.f, fpos)
+	if err != nil {
+		return 0, err
+	}
+
+	if fpos < n {
+		skipped++
+	}
+	cf.skippedLines = skipped
+
+	if _, err := cf.f.Seek(fpos, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	cf.read = n
+	cf.eof = n == cf.size
+	if !cf.eof && n > 0 {
+		// The new line is appended depending on the last read byte.
+		var b = make([]byte, 1)
+		if _, err := cf.f.ReadAt(b, n-1); err != nil {
+			return 0, err
+		}
+
+		cf.last = b[0]
+	}
+
+	return n, nil
+}
+
+// Read reads the content of cf, appending a new line at its end if it doesn't
+// end with one.
+func (cf *csvFile) Read(p []byte) (int, error) {
+	if cf.eof {
+		return 0, io.EOF
+	}
+
+	var n, err = cf.r.Read(p)
+	if n > 0 {
+		cf.read += int64(n)
+		cf.lines += countLines(p[:n])
+		cf.last = p[n-1]
+		return n, nil
+	}
+
+	if err == io.EOF {
+		cf.eof = true
+		if cf.last != 0 && cf.last != '\n' && len(p) > 0 {
+			p[0] = '\n'
+			cf.last = '\n'
+			cf.read++
+			cf.lines++
+			return 1, nil
+		}
+	}
+
+	return 0, err
+}
+
+func (cf *csvFile) close() error {
+	if cf.dec != nil {
+		_ = cf.dec.Close()
+	}
+
+	var err = cf.f.Close()
+	if cf.temp {
+		_ = os.Remove(cf.f.Name())
+	}
+
+	return err
+}
+
+func (s *csvSource) Read(p []byte) (int, error) {
+	for s.cur < len(s.files) {
+		var cf = s.files[s.cur]
+		var n, err = cf.Read(p)
+		if n > 0 {
+			return n, nil
+		}
+
+		if err == io.EOF {
+			s.cur++
+			continue
+		}
+
+		if err != nil {
+			return 0, &readError{name: cf.name, err: err}
+		}
+	}
+
+	return 0, io.EOF
+}
+
+// readError is an error returned by the Read method of a csvSource, which
+// already indicates the file where it happened.
+type readError struct {
+	name string
+	err  error
+}
+
+func (e *readError) Error() string {
+	return fmt.Sprintf("Error while reading the CSV (%s): %s", e.name, e.err.Error())
+}
+
+func countLines(p []byte) int {
+	var n int
+	for _, b := range p {
+		if b == '\n' {
+			n++
+		}
+	}
+
+	return n
+}
+
+// countFileLines returns the number of lines of the first n bytes of f.
+func countFileLines(f *os.File, n int64) (int, error) {
+	var (
+		lines int
+		buf   = make([]byte, 32*1024)
+		sr    = io.NewSectionReader(f, 0, n)
+	)
+
+	for {
+		var m, err = sr.Read(buf)
+		lines += countLines(buf[:m])
+		if err == io.EOF {
+			return lines, nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// size returns the total number of bytes of the source or -1 if it's unknown
+// for any of its files.
+func (s *csvSource) size() int64 {
+	var total int64
+	for _, cf := range s.files {
+		if cf.size < 0 {
+			return -1
+		}
+
+		total += cf.size
+	}
+
+	return total
+}
+
+// stdin reports if one of the files is the standard input without having been
+// copied to a temporary file.
+func (s *csvSource) stdin() bool {
+	for _, cf := range s.files {
+		if cf.f == os.Stdin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// seek sets the position of the next read to offset from the beginning of the
+// source. It fails if any of the files is the standard input without being
+// copied to a temporary file.
+func (s *csvSource) seek(offset int64) error {
+	var remaining = offset
+	s.cur = len(s.files)
+	for i, cf := range s.files {
+		if err := cf.reset(); err != nil {
+			return fmt.Errorf("Error while seeking the CSV (%s): %s", cf.name, err.Error())
+		}
+
+		if remaining > 0 {
+			var n, err = cf.skip(remaining)
+			if err != nil {
+				return fmt.Errorf("Error while seeking the CSV (%s): %s", cf.name, err.Error())
+			}
+
+			remaining -= n
+		}
+
+		if !cf.eof && s.cur == len(s.files) {
+			s.cur = i
+		}
+	}
+
+	return nil
+}
+
+// rewind sets the position of the next read to the beginning of the source for
+// reading it again.
+func (s *csvSource) rewind() error {
+	return s.seek(0)
+}
+
+// locate returns the file which contains the byte before offset of the source.
+func (s *csvSource) locate(offset int64) *csvFile {
+	var start int64
+	for i, cf := range s.files {
+		if !cf.eof || i == len(s.files)-1 || offset <= start+cf.read {
+			return cf
+		}
+
+		start += cf.read
+	}
+
+	return nil
+}
+
+// locateLine returns the file which contains the line of the source and the
+// number of lines read from the files which precede it, less the lines skipped
+// from the beginning of the file, so subtracting it from line returns the line
+// relative to the file.
+func (s *csvSource) locateLine(line int) (*csvFile, int) {
+	var lines int
+	for i, cf := range s.files {
+		if !cf.eof || i == len(s.files)-1 || line <= lines+cf.lines {
+			return cf, lines - cf.skippedLines
+		}
+
+		lines += cf.lines
+	}
+
+	return nil, 0
+}
+
+// position returns the file which contains the line of the source and the line
+// relative to it, as file:line.
+func (s *csvSource) position(line int) string {
+	var cf, base = s.locateLine(line)
+	return fmt.Sprintf("%s:%d", cf.name, line-base)
+}
+
+// annotate returns err with the name of the file where it happened and, if it's
+// a csv.ParseError, with its lines relative to such file. base is the offset of
+// the source where r started to read.
+func (s *csvSource) annotate(err error, r *csv.Reader, base int64) error {
+	var rerr *readError
+	if err == nil || errors.Is(err, context.Canceled) || errors.As(err, &rerr) || len(s.files) == 0 {
+		return err
+	}
+
+	var perr *csv.ParseError
+	if !errors.As(err, &perr) {
+		return fmt.Errorf("Error in the CSV (%s): %s", s.locate(base+r.InputOffset()).name, err.Error())
+	}
+
+	var cf, lines = s.locateLine(perr.Line)
+	var rel = *perr
+	rel.Line -= lines
+	if rel.StartLine > lines+cf.skippedLines {
+		rel.StartLine -= lines
+	} else {
+		rel.StartLine = rel.Line
+	}
+
+	return fmt.Errorf("Error in the CSV (%s): %s", cf.name, rel.Error())
+}
+
+func (s *csvSource) close() error {
+	var err error
+	for _, cf := range s.files {
+		if e := cf.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
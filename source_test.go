@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sourceRecord returns a record of the remote build service CSV exports of the
+// build id which finished at the minute min of 2018-10-31 UTC.
+func sourceRecord(id string, min int) string {
+	var end = time.Date(2018, 10, 31, 10, min, 0, 0, time.UTC).Format(time.RFC3339)
+	return id + ",userA," + end + "," + end + "," + end + ",false,1,100"
+}
+
+// writeSourceFiles writes the files of contents in a temporary directory,
+// compressing with gzip the ones whose name ends with .gz, and returns their
+// paths.
+func writeSourceFiles(t *testing.T, names []string, contents []string) []string {
+	t.Helper()
+
+	var (
+		dir   = t.TempDir()
+		paths []string
+	)
+
+	for i, n := range names {
+		var data = []byte(contents[i])
+		if strings.HasSuffix(n, ".gz") {
+			var buf bytes.Buffer
+			var zw = gzip.NewWriter(&buf)
+			_, err := zw.Write(data)
+			require.NoError(t, err)
+			require.NoError(t, zw.Close())
+			data = buf.Bytes()
+		}
+
+		var p = filepath.Join(dir, n)
+		require.NoError(t, os.WriteFile(p, data, 0o600))
+		paths = append(paths, p)
+	}
+
+	return paths
+}
+
+func TestCSVSource(t *testing.T) {
+	var (
+		names = []string{"a.csv", "b.csv.gz", "c.csv"}
+		// a.csv doesn't end with a new line.
+		contents = []string{
+			sourceRecord("a1", 1) + "\n" + sourceRecord("a2", 2),
+			sourceRecord("b1", 3) + "\n" + sourceRecord("b2", 4) + "\n",
+			sourceRecord("c1", 5) + "\n" + sourceRecord("c2", 6) + "\n" + sourceRecord("c3", 7) + "\n",
+		}
+		from = time.Date(2018, 10, 31, 0, 0, 0, 0, time.UTC)
+		to   = time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	t.Run("files are read one after the other", func(t *testing.T) {
+		var src, err = openSource(writeSourceFiles(t, names, contents), false)
+		require.NoError(t, err)
+		defer src.close()
+
+		assert.Equal(t, int64(-1), src.size(), "the size of the compressed file is unknown")
+
+		var recs, rerr = csv.NewReader(src).ReadAll()
+		require.NoError(t, rerr)
+		var ids []string
+		for _, r := range recs {
+			ids = append(ids, r[0])
+		}
+		assert.Equal(t, []string{"a1", "a2", "b1", "b2", "c1", "c2", "c3"}, ids)
+
+		for line, expected := range map[int]struct {
+			name string
+			line int
+		}{1: {"a.csv", 1}, 2: {"a.csv", 2}, 3: {"b.csv.gz", 1}, 7: {"c.csv", 3}} {
+			var cf, lines = src.locateLine(line)
+			assert.Equal(t, expected.name, filepath.Base(cf.name), "line %d", line)
+			assert.Equal(t, expected.line, line-lines, "line %d", line)
+		}
+	})
+
+	t.Run("size of uncompressed files", func(t *testing.T) {
+		var src, err = openSource(writeSourceFiles(t, []string{"a.csv", "c.csv"}, []string{contents[0], contents[2]}), false)
+		require.NoError(t, err)
+		defer src.close()
+
+		// The new line appended to a.csv is counted.
+		assert.Equal(t, int64(len(contents[0])+1+len(contents[2])), src.size())
+	})
+
+	var tcases = []struct {
+		desc string
+		// invalid replaces the content of the file of its index.
+		invalid  map[int]string
+		expected string
+	}{
+		{
+			desc:     "invalid time in the first file",
+			invalid:  map[int]string{0: sourceRecord("a1", 1) + "\n" + strings.TrimSuffix(sourceRecord("a2", 2), ":00Z,false,1,100") + ",false,1,100"},
+			expected: "a.csv): parse error on line 2, column 4",
+		},
+		{
+			desc:     "invalid time in the compressed file",
+			invalid:  map[int]string{1: sourceRecord("b1", 3) + "\n" + strings.TrimSuffix(sourceRecord("b2", 4), ":00Z,false,1,100") + ",false,1,100" + "\n"},
+			expected: "b.csv.gz): parse error on line 2, column 4",
+		},
+		{
+			desc:     "bare quote in the last file",
+			invalid:  map[int]string{2: sourceRecord("c1", 5) + "\n" + sourceRecord("c2", 6) + "\n" + `c3,"user"A,x` + "\n"},
+			expected: "c.csv): parse error on line 3, column 9: extraneous or missing \" in quoted-field",
+		},
+		{
+			desc:     "wrong number of fields in the last file",
+			invalid:  map[int]string{2: sourceRecord("c1", 5) + "\n" + "c2,userA\n"},
+			expected: "c.csv): record on line 2: wrong number of fields",
+		},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run("error: "+tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var cs = append([]string(nil), contents...)
+			for i, c := range tc.invalid {
+				cs[i] = c
+			}
+
+			var src, err = openSource(writeSourceFiles(t, names, cs), false)
+			require.NoError(t, err)
+			defer src.close()
+
+			var r = csv.NewReader(src)
+			_, err = stats.ComputeBuilds(r, from, to)
+			err = src.annotate(err, r, 0)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), string(filepath.Separator)+tc.expected)
+			}
+		})
+	}
+
+	t.Run("resume from the checkpoints", func(t *testing.T) {
+		// Every checkpoint, including the ones inside of the compressed file,
+		// must resume to the stats of the whole source and report the errors on
+		// the line of their file.
+		var src, err = openSource(writeSourceFiles(t, names, contents), false)
+		require.NoError(t, err)
+		defer src.close()
+
+		var checkpoints []stats.Checkpoint
+		var opts = stats.Options{
+			CheckpointInterval: 1,
+			Checkpoint: func(cp *stats.Checkpoint) error {
+				var data, err = cp.State.MarshalJSON()
+				require.NoError(t, err)
+
+				var state stats.Aggregator
+				require.NoError(t, state.UnmarshalJSON(data))
+				checkpoints = append(checkpoints, stats.Checkpoint{Offset: cp.Offset, Line: cp.Line, State: &state})
+				return nil
+			},
+		}
+
+		expected, err := stats.ComputeBuildsContext(context.Background(), csv.NewReader(src), from, to, opts)
+		require.NoError(t, err)
+		require.Len(t, checkpoints, 7)
+
+		for i := range checkpoints {
+			var cp = checkpoints[i]
+			require.NoError(t, src.seek(cp.Offset))
+			var b, err = stats.ComputeBuildsContext(
+				context.Background(), csv.NewReader(src), from, to, stats.Options{Resume: &cp},
+			)
+			require.NoError(t, err, "checkpoint %d", i)
+			assert.Equal(t, expected, b, "checkpoint %d", i)
+		}
+
+		var invalids = []struct {
+			// file is the index of the file whose content is replaced and
+			// checkpoints the number of checkpoints before the invalid record.
+			file        int
+			content     string
+			checkpoints int
+			expected    string
+		}{
+			{
+				file:        1,
+				content:     tcases[1].invalid[1],
+				checkpoints: 3,
+				expected:    "b.csv.gz): parse error on line 2, column 4",
+			},
+			{
+				file:        2,
+				content:     tcases[2].invalid[2],
+				checkpoints: 6,
+				expected:    "c.csv): parse error on line 3, column 9",
+			},
+		}
+
+		for _, inv := range invalids {
+			var cs = append([]string(nil), contents...)
+			cs[inv.file] = inv.content
+
+			var src, err = openSource(writeSourceFiles(t, names, cs), false)
+			require.NoError(t, err)
+			defer src.close()
+
+			for i := range checkpoints[:inv.checkpoints] {
+				var cp = checkpoints[i]
+				require.NoError(t, src.seek(cp.Offset))
+
+				var r = csv.NewReader(src)
+				_, err = stats.ComputeBuildsContext(context.Background(), r, from, to, stats.Options{Resume: &cp})
+				err = src.annotate(err, r, cp.Offset)
+				if assert.Error(t, err, "checkpoint %d", i) {
+					assert.Contains(t, err.Error(), string(filepath.Separator)+inv.expected, "checkpoint %d", i)
+				}
+			}
+		}
+	})
+
+	t.Run("standard input", func(t *testing.T) {
+		var pr, pw, err = os.Pipe()
+		require.NoError(t, err)
+		go func() {
+			_, _ = io.WriteString(pw, contents[2])
+			_ = pw.Close()
+		}()
+
+		var stdin = os.Stdin
+		os.Stdin = pr
+		defer func() { os.Stdin = stdin }()
+
+		// The spooled standard input can be read again.
+		src, err := openSource(append([]string{stdinPath}, writeSourceFiles(t, names[:1], contents[:1])...), true)
+		require.NoError(t, err)
+		defer src.close()
+
+		assert.False(t, src.stdin())
+		for i := 0; i < 2; i++ {
+			var recs, err = csv.NewReader(src).ReadAll()
+			require.NoError(t, err)
+			assert.Len(t, recs, 5)
+			var cf, lines = src.locateLine(3)
+			assert.Equal(t, "stdin", cf.name)
+			assert.Equal(t, 3, 3-lines)
+			require.NoError(t, src.rewind())
+		}
+
+		_, err = openSource([]string{stdinPath, stdinPath}, true)
+		assert.Error(t, err)
+	})
+}
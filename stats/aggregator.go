@@ -0,0 +1,320 @@
+package stats
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Aggregator accumulates the records of a time window for computing their
+// Builds stats. It doesn't check if the added records are inside of the time
+// window, that's a responsibility of the caller.
+// Its state can be serialized with encoding/json for continuing the
+// accumulation later.
+type Aggregator struct {
+	from      time.Time
+	to        time.Time
+	bounds    Bounds
+	num       uint64
+	numFailed uint64
+	users     map[string]Counts
+	errCodes  map[uint8]uint64
+	timelineG Granularity
+	loc       *time.Location
+	hours     hourBuckets
+	groupBy   string
+	groups    *groupSet
+}
+
+// NewAggregator returns an empty Aggregator for the passed time window.
+func NewAggregator(from time.Time, to time.Time) *Aggregator {
+	return &Aggregator{
+		from:     from,
+		to:       to,
+		users:    map[string]Counts{},
+		errCodes: map[uint8]uint64{},
+	}
+}
+
+// EnableTimeline makes the Builds returned by a to have a timeline of g, whose
+// buckets start at the hours, days or weeks of loc (UTC if it's nil); it must
+// be called before adding any record.
+func (a *Aggregator) EnableTimeline(g Granularity, loc *time.Location) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	a.timelineG = g
+	a.loc = loc
+	if g != GranularityNone && a.hours == nil {
+		a.hours = hourBuckets{}
+	}
+}
+
+// EnableGroups makes the Builds returned by a to have the groups of the
+// Dimension whose name is groupBy, see DimensionByName, with the times of the
+// records in loc (UTC if it's nil); it must be called before adding any record
+// and loc must be the same of EnableTimeline.
+func (a *Aggregator) EnableGroups(groupBy string, loc *time.Location) error {
+	var d, err = dimensionIn(groupBy, loc)
+	if err != nil {
+		return err
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	a.groupBy = groupBy
+	a.loc = loc
+	a.groups = newGroupSet(d)
+	return nil
+}
+
+// SetBounds sets the limits of the time window which are included in it, which
+// are closed by default; it's only informative, see Builds.Bounds.
+func (a *Aggregator) SetBounds(b Bounds) {
+	a.bounds = b
+}
+
+// Add accumulates rec.
+func (a *Aggregator) Add(rec *Record) {
+	if a.hours != nil {
+		a.hours.add(rec, a.loc)
+	}
+
+	if a.groups != nil {
+		a.groups.add(rec)
+	}
+
+	var uc = a.users[rec.UserID]
+
+	a.num++
+	uc.Num++
+
+	if rec.ExitCode > 0 {
+		a.numFailed++
+		uc.NumFailed++
+		a.errCodes[rec.ExitCode]++
+	}
+
+	a.users[rec.UserID] = uc
+}
+
+// Remove discounts rec, which must have been accumulated before; it's the
+// inverse of Add. It cannot be used with groups, see EnableGroups, because the
+// minimum and maximum durations cannot be discounted.
+func (a *Aggregator) Remove(rec *Record) {
+	if a.hours != nil {
+		a.hours.remove(rec, a.loc)
+	}
+
+	var uc = a.users[rec.UserID]
+
+	a.num--
+	uc.Num--
+
+	if rec.ExitCode > 0 {
+		a.numFailed--
+		uc.NumFailed--
+
+		a.errCodes[rec.ExitCode]--
+		if a.errCodes[rec.ExitCode] == 0 {
+			delete(a.errCodes, rec.ExitCode)
+		}
+	}
+
+	if uc.Num == 0 {
+		delete(a.users, rec.UserID)
+	} else {
+		a.users[rec.UserID] = uc
+	}
+}
+
+// Window returns the time window of the aggregator.
+func (a *Aggregator) Window() (from time.Time, to time.Time) {
+	return a.from, a.to
+}
+
+// Bounds returns the limits of the time window which are included in it.
+func (a *Aggregator) Bounds() Bounds {
+	return a.bounds
+}
+
+// Num returns the number of accumulated records.
+func (a *Aggregator) Num() uint64 {
+	return a.num
+}
+
+// Builds returns the stats of the accumulated records.
+// Users with the same number of builds are ranked by their ID and exit codes
+// with the same number of builds by their value, so the result is always the
+// same for the same set of records.
+func (a *Aggregator) Builds() *Builds {
+	var b = Builds{
+		From:      a.from,
+		To:        a.to,
+		Bounds:    a.bounds,
+		Num:       a.num,
+		NumFailed: a.numFailed,
+		Users:     make(map[string]Counts, len(a.users)),
+		ErrCodes:  make(map[uint8]uint64, len(a.errCodes)),
+	}
+	b.RateSuccess = b.counts().RateSuccess()
+
+	for u, c := range a.users {
+		b.Users[u] = c
+	}
+
+	for c, n := range a.errCodes {
+		b.ErrCodes[c] = n
+	}
+
+	if a.timelineG != GranularityNone {
+		b.TimelineGranularity, b.Timeline = a.hours.timeline(a.timelineG, a.loc)
+	}
+
+	if a.groups != nil {
+		b.Groups = a.groups.clone().sorted()
+	}
+
+	for i, u := range b.RankedUsers() {
+		if i == len(b.TopUsers) {
+			break
+		}
+		b.TopUsers[i] = u.UserID
+	}
+
+	for i, c := range b.RankedErrCodes() {
+		if i == len(b.TopErrCodes) {
+			break
+		}
+		b.TopErrCodes[i] = c.ExitCode
+	}
+
+	return &b
+}
+
+// clone returns a copy of a which doesn't share any state with it.
+func (a *Aggregator) clone() *Aggregator {
+	var c = *a
+	c.users = make(map[string]Counts, len(a.users))
+	c.errCodes = make(map[uint8]uint64, len(a.errCodes))
+
+	for u, uc := range a.users {
+		c.users[u] = uc
+	}
+
+	for ec, n := range a.errCodes {
+		c.errCodes[ec] = n
+	}
+
+	if a.hours != nil {
+		c.hours = make(hourBuckets, len(a.hours))
+		for h, hc := range a.hours {
+			c.hours[h] = hc
+		}
+	}
+
+	if a.groups != nil {
+		c.groups = a.groups.clone()
+	}
+
+	return &c
+}
+
+// aggregatorState is the serialized representation of an Aggregator.
+type aggregatorState struct {
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Bounds    Bounds            `json:"bounds,omitempty"`
+	Num       uint64            `json:"num"`
+	NumFailed uint64            `json:"num_failed"`
+	Users     map[string]Counts `json:"users"`
+	ErrCodes  map[uint8]uint64  `json:"err_codes"`
+	Timeline  Granularity       `json:"timeline,omitempty"`
+	Location  string            `json:"location,omitempty"`
+	Hours     map[int64]Counts  `json:"hours,omitempty"`
+	GroupBy   string            `json:"group_by,omitempty"`
+	Groups    []groupState      `json:"groups,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a *Aggregator) MarshalJSON() ([]byte, error) {
+	return json.Marshal(aggregatorState{
+		From:      a.from,
+		To:        a.to,
+		Bounds:    a.bounds,
+		Num:       a.num,
+		NumFailed: a.numFailed,
+		Users:     a.users,
+		ErrCodes:  a.errCodes,
+		Timeline:  a.timelineG,
+		Location:  locationName(a.loc),
+		Hours:     a.hours,
+		GroupBy:   a.groupBy,
+		Groups:    a.groupStates(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *Aggregator) UnmarshalJSON(data []byte) error {
+	var s aggregatorState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*a = *NewAggregator(s.From, s.To)
+	a.bounds = s.Bounds
+	a.num = s.Num
+	a.numFailed = s.NumFailed
+
+	if s.Users != nil {
+		a.users = s.Users
+	}
+
+	if s.ErrCodes != nil {
+		a.errCodes = s.ErrCodes
+	}
+
+	var loc *time.Location
+	if s.Location != "" {
+		var err error
+		if loc, err = time.LoadLocation(s.Location); err != nil {
+			return err
+		}
+	}
+
+	a.EnableTimeline(s.Timeline, loc)
+	for h, c := range s.Hours {
+		a.hours[h] = c
+	}
+
+	if s.GroupBy != "" {
+		if err := a.EnableGroups(s.GroupBy, loc); err != nil {
+			return err
+		}
+
+		a.groups.restore(s.Groups)
+	}
+
+	return nil
+}
+
+// groupStates returns the serialized representation of the groups, which is
+// nil if they aren't enabled.
+func (a *Aggregator) groupStates() []groupState {
+	if a.groups == nil {
+		return nil
+	}
+
+	return a.groups.states()
+}
+
+// locationName returns the name of loc, which is empty if loc is nil.
+func locationName(loc *time.Location) string {
+	if loc == nil {
+		return ""
+	}
+
+	return loc.String()
+}
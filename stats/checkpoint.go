@@ -0,0 +1,74 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// DefaultCheckpointInterval is the number of read records between calls to the
+// Options.Checkpoint function when Options.CheckpointInterval is 0.
+const DefaultCheckpointInterval = 1000000
+
+// Checkpoint is the state of a computation of ComputeBuildsContext at some point
+// of its input, which allows to continue it later, see Options.Resume.
+type Checkpoint struct {
+	// Offset is the byte offset of the input from where the computation
+	// continues.
+	Offset int64 `json:"offset"`
+	// Line is the number of records read before Offset.
+	Line uint64 `json:"line"`
+	// State has the accumulated stats of the records read before Offset.
+	State *Aggregator `json:"state"`
+}
+
+// SaveCheckpoint writes cp in the file of path. The file is replaced
+// atomically, so it always contains a complete checkpoint, even if the process
+// is interrupted while writing it.
+func SaveCheckpoint(path string, cp *Checkpoint) error {
+	var data, err = json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// LoadCheckpoint reads the checkpoint saved by SaveCheckpoint in the file of
+// path.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	var data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+
+	if cp.State == nil || cp.Offset < 0 {
+		return nil, errors.New("Invalid checkpoint. It doesn't have a valid state or offset")
+	}
+
+	return &cp, nil
+}
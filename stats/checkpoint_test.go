@@ -0,0 +1,157 @@
+package stats_test
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	var records = append([]string{}, recordsUserA...)
+	records = append(records, recordsUserB...)
+	records = append(records, genRecord(expectedBuilds.To.Add(time.Hour), "userA", 0))
+	records = append(records, recordsUserC...)
+	records = append(records, recordsUserD...)
+	records = append(records, recordsUserE...)
+	records = append(records, recordsUserF...)
+
+	var in = strings.Join(records, "\n")
+
+	expected, err := stats.ComputeBuilds(
+		csv.NewReader(strings.NewReader(in)), expectedBuilds.From, expectedBuilds.To,
+	)
+	require.NoError(t, err)
+
+	t.Run("successful: resume interrupted computation", func(t *testing.T) {
+		var (
+			path        = filepath.Join(t.TempDir(), "checkpoint.json")
+			ctx, cancel = context.WithCancel(context.Background())
+			saved       int
+		)
+		defer cancel()
+
+		_, err := stats.ComputeBuildsContext(
+			ctx, csv.NewReader(strings.NewReader(in)), expectedBuilds.From, expectedBuilds.To,
+			stats.Options{
+				Checkpoint: func(cp *stats.Checkpoint) error {
+					saved++
+					if saved == 3 {
+						cancel()
+					}
+					return stats.SaveCheckpoint(path, cp)
+				},
+				CheckpointInterval: 7,
+			},
+		)
+		require.Equal(t, context.Canceled, err)
+		// The last checkpoint is the one of where the computation stopped.
+		assert.Equal(t, 4, saved)
+
+		cp, err := stats.LoadCheckpoint(path)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(22), cp.Line)
+
+		// The counts of the users are snake_case like the rest of the fields.
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"users":{"userA":{"num":`)
+		assert.Contains(t, string(data), `"num_failed":`)
+		assert.NotContains(t, string(data), `"NumFailed"`)
+
+		b, err := stats.ComputeBuildsContext(
+			context.Background(), csv.NewReader(strings.NewReader(in[cp.Offset:])),
+			expectedBuilds.From, expectedBuilds.To,
+			stats.Options{Resume: cp},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, expected, b)
+	})
+
+	t.Run("successful: resume from the checkpoint of the cancellation", func(t *testing.T) {
+		// The records read after the cancellation are inside and outside of the
+		// time window.
+		for n := 1; n < len(records); n++ {
+			var (
+				ctx, cancel = context.WithCancel(context.Background())
+				last        stats.Checkpoint
+				saved       int
+			)
+
+			_, err := stats.ComputeBuildsContext(
+				ctx, csv.NewReader(strings.NewReader(in)), expectedBuilds.From, expectedBuilds.To,
+				stats.Options{
+					Checkpoint: func(cp *stats.Checkpoint) error {
+						saved++
+						if saved == n {
+							cancel()
+						}
+
+						var data, err = cp.State.MarshalJSON()
+						require.NoError(t, err)
+
+						var state stats.Aggregator
+						require.NoError(t, state.UnmarshalJSON(data))
+						last = stats.Checkpoint{Offset: cp.Offset, Line: cp.Line, State: &state}
+						return nil
+					},
+					CheckpointInterval: 1,
+				},
+			)
+			cancel()
+			require.Equal(t, context.Canceled, err, "cancelled after record %d", n)
+			assert.Equal(t, uint64(n+1), last.Line, "cancelled after record %d", n)
+
+			b, err := stats.ComputeBuildsContext(
+				context.Background(), csv.NewReader(strings.NewReader(in[last.Offset:])),
+				expectedBuilds.From, expectedBuilds.To,
+				stats.Options{Resume: &last},
+			)
+			require.NoError(t, err, "cancelled after record %d", n)
+			assert.Equal(t, expected, b, "cancelled after record %d", n)
+		}
+	})
+
+	t.Run("error: resume with a different time window", func(t *testing.T) {
+		var cp *stats.Checkpoint
+		_, err := stats.ComputeBuildsContext(
+			context.Background(), csv.NewReader(strings.NewReader(in)),
+			expectedBuilds.From, expectedBuilds.To,
+			stats.Options{
+				Checkpoint: func(c *stats.Checkpoint) error {
+					cp = c
+					return nil
+				},
+				CheckpointInterval: 10,
+			},
+		)
+		require.NoError(t, err)
+		require.NotNil(t, cp)
+
+		_, err = stats.ComputeBuildsContext(
+			context.Background(), csv.NewReader(strings.NewReader(in[cp.Offset:])),
+			expectedBuilds.From, expectedBuilds.To.Add(time.Hour),
+			stats.Options{Resume: cp},
+		)
+		assert.Error(t, err)
+
+		_, err = stats.ComputeBuildsContext(
+			context.Background(), csv.NewReader(strings.NewReader(in[cp.Offset:])),
+			expectedBuilds.From, expectedBuilds.To,
+			stats.Options{Resume: cp, Bounds: stats.BoundsHalfOpen},
+		)
+		assert.Error(t, err)
+	})
+
+	t.Run("error: load invalid checkpoint", func(t *testing.T) {
+		_, err := stats.LoadCheckpoint(filepath.Join(t.TempDir(), "not-exist.json"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,110 @@
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a compression format of the CSV files which
+// NewDecompressReader decompresses.
+type Compression uint8
+
+// The compression formats.
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+	CompressionBzip2
+)
+
+// String returns the name of the compression format.
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionBzip2:
+		return "bzip2"
+	default:
+		return "unknown"
+	}
+}
+
+// The magic bytes which the compressed data starts with.
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+)
+
+// DetectCompression returns the compression format indicated by the magic
+// bytes which header starts with or, when it doesn't start with any, by the
+// extension of name: .gz, .zst or .bz2.
+func DetectCompression(header []byte, name string) Compression {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(header, zstdMagic):
+		return CompressionZstd
+	case bytes.HasPrefix(header, bzip2Magic):
+		return CompressionBzip2
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz", ".gzip":
+		return CompressionGzip
+	case ".zst", ".zstd":
+		return CompressionZstd
+	case ".bz2", ".bzip2":
+		return CompressionBzip2
+	default:
+		return CompressionNone
+	}
+}
+
+// NewDecompressReader returns a reader of the decompressed content of r, whose
+// compression format is detected by DetectCompression from its first bytes and
+// name (which can be empty), and such format. The reader returns the content
+// of r as it is when it isn't compressed.
+// Closing the returned reader doesn't close r.
+func NewDecompressReader(r io.Reader, name string) (io.ReadCloser, Compression, error) {
+	var (
+		br        = bufio.NewReader(r)
+		header, _ = br.Peek(len(zstdMagic))
+		c         = DetectCompression(header, name)
+	)
+
+	switch c {
+	case CompressionGzip:
+		var gr, err = gzip.NewReader(br)
+		if err != nil {
+			return nil, c, err
+		}
+
+		return gr, c, nil
+
+	case CompressionZstd:
+		var zr, err = zstd.NewReader(br)
+		if err != nil {
+			return nil, c, err
+		}
+
+		return zr.IOReadCloser(), c, nil
+
+	case CompressionBzip2:
+		return io.NopCloser(bzip2.NewReader(br)), c, nil
+
+	default:
+		return io.NopCloser(br), c, nil
+	}
+}
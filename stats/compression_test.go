@@ -0,0 +1,125 @@
+package stats_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bzip2Record is the output of bzip2 -9 for bzip2RecordPlain, because the
+// standard library doesn't have a bzip2 compressor.
+var bzip2Record = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xf2, 0x18,
+	0xdc, 0x80, 0x00, 0x00, 0x30, 0x5b, 0x80, 0x00, 0x10, 0x00, 0x06, 0x7e,
+	0xf0, 0x04, 0x00, 0x33, 0x04, 0x0a, 0x00, 0x20, 0x00, 0x50, 0xa0, 0x69,
+	0xa1, 0x91, 0x93, 0x10, 0x64, 0x13, 0x42, 0x7a, 0x99, 0x32, 0x34, 0x9d,
+	0x44, 0x00, 0x95, 0xba, 0xf6, 0x5c, 0x8b, 0x23, 0x1c, 0x18, 0x39, 0x32,
+	0x71, 0xcd, 0xf8, 0xe6, 0xb7, 0x32, 0x81, 0xc0, 0x92, 0xaf, 0x42, 0x93,
+	0x95, 0x73, 0xd6, 0x18, 0x33, 0x35, 0x0a, 0xa5, 0xec, 0xe5, 0xf4, 0xb9,
+	0x82, 0x3d, 0xdf, 0x2d, 0xf7, 0x17, 0xf1, 0x77, 0x24, 0x53, 0x85, 0x09,
+	0x0f, 0x21, 0x8d, 0xc8, 0x00,
+}
+
+const bzip2RecordPlain = "b1,u1,2018-10-31T01:54:32-04:00,2018-10-31T01:55:14-04:00,2018-10-31T02:47:31-04:00,false,0,945058189\n"
+
+func TestNewDecompressReader(t *testing.T) {
+	var plain = strings.Join(recordsUserA, "\n")
+
+	var gz bytes.Buffer
+	var gw = gzip.NewWriter(&gz)
+	_, err := gw.Write([]byte(plain))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	zw, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	var zst = zw.EncodeAll([]byte(plain), nil)
+
+	var tcases = []struct {
+		desc        string
+		data        []byte
+		name        string
+		compression stats.Compression
+		expected    string
+	}{
+		{
+			desc:        "gzip",
+			data:        gz.Bytes(),
+			name:        "builds.csv.gz",
+			compression: stats.CompressionGzip,
+			expected:    plain,
+		},
+		{
+			desc:        "zstd without extension",
+			data:        zst,
+			compression: stats.CompressionZstd,
+			expected:    plain,
+		},
+		{
+			desc:        "bzip2 with a misleading extension",
+			data:        bzip2Record,
+			name:        "builds.csv.gz",
+			compression: stats.CompressionBzip2,
+			expected:    bzip2RecordPlain,
+		},
+		{
+			desc:        "uncompressed",
+			data:        []byte(plain),
+			name:        "builds.csv",
+			compression: stats.CompressionNone,
+			expected:    plain,
+		},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var r, c, err = stats.NewDecompressReader(bytes.NewReader(tc.data), tc.name)
+			require.NoError(t, err)
+			defer r.Close()
+
+			assert.Equal(t, tc.compression, c)
+
+			content, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(content))
+		})
+	}
+
+	t.Run("compute builds", func(t *testing.T) {
+		var r, _, err = stats.NewDecompressReader(bytes.NewReader(zst), "")
+		require.NoError(t, err)
+		defer r.Close()
+
+		b, err := stats.ComputeBuilds(csv.NewReader(r), expectedBuilds.From, expectedBuilds.To)
+		require.NoError(t, err)
+
+		expected, err := stats.ComputeBuilds(
+			csv.NewReader(strings.NewReader(plain)), expectedBuilds.From, expectedBuilds.To,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, expected, b)
+	})
+
+	t.Run("error: corrupted data", func(t *testing.T) {
+		var _, _, err = stats.NewDecompressReader(strings.NewReader("not gzip"), "builds.csv.gz")
+		assert.Error(t, err)
+	})
+}
+
+func TestDetectCompression(t *testing.T) {
+	assert.Equal(t, stats.CompressionGzip, stats.DetectCompression(nil, "builds.CSV.GZ"))
+	assert.Equal(t, stats.CompressionZstd, stats.DetectCompression([]byte("a,b"), "builds.csv.zst"))
+	assert.Equal(t, stats.CompressionBzip2, stats.DetectCompression([]byte("BZh9"), "builds.csv"))
+	assert.Equal(t, stats.CompressionNone, stats.DetectCompression([]byte("a,b"), "builds.csv"))
+}
@@ -0,0 +1,69 @@
+package stats
+
+import "math"
+
+// ConfidenceZ is the standard score used for computing the confidence
+// intervals of the success rates; it corresponds to a 95% confidence level.
+const ConfidenceZ = 1.959963984540054
+
+// MinReliableSample is the minimum number of builds which a success rate must
+// be computed from for not being considered a small sample.
+const MinReliableSample = 30
+
+// Interval is a confidence interval of a rate; both limits are between 0 and 1.
+type Interval struct {
+	Lower float64
+	Upper float64
+}
+
+// WilsonInterval returns the Wilson score interval of the rate of successes
+// over total for the z standard score.
+// The interval is [0, 1] when total is 0 because nothing is known about the
+// rate.
+func WilsonInterval(successes uint64, total uint64, z float64) Interval {
+	if total == 0 {
+		return Interval{Lower: 0, Upper: 1}
+	}
+
+	var (
+		n      = float64(total)
+		p      = float64(successes) / n
+		z2     = z * z
+		denom  = 1 + z2/n
+		center = (p + z2/(2*n)) / denom
+		margin = z * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / denom
+	)
+
+	return Interval{
+		Lower: math.Max(0, center-margin),
+		Upper: math.Min(1, center+margin),
+	}
+}
+
+// Counts contains the number of builds and how many of them failed.
+type Counts struct {
+	Num       uint64 `json:"num"`
+	NumFailed uint64 `json:"num_failed"`
+}
+
+// RateSuccess returns the rate of succeeded builds; it's 0 when there aren't
+// builds.
+func (c Counts) RateSuccess() float32 {
+	if c.Num == 0 {
+		return 0
+	}
+
+	return float32(c.Num-c.NumFailed) / float32(c.Num)
+}
+
+// RateSuccessInterval returns the Wilson score interval of the rate of
+// succeeded builds with a 95% confidence level.
+func (c Counts) RateSuccessInterval() Interval {
+	return WilsonInterval(c.Num-c.NumFailed, c.Num, ConfidenceZ)
+}
+
+// SmallSample reports if the number of builds is lower than MinReliableSample,
+// hence its success rate isn't trustworthy.
+func (c Counts) SmallSample() bool {
+	return c.Num < MinReliableSample
+}
@@ -0,0 +1,69 @@
+package stats_test
+
+import (
+	"testing"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWilsonInterval(t *testing.T) {
+	var tcases = []struct {
+		desc      string
+		successes uint64
+		total     uint64
+		expected  stats.Interval
+	}{
+		{
+			desc:     "no builds",
+			expected: stats.Interval{Lower: 0, Upper: 1},
+		},
+		{
+			desc:      "all succeeded with a small sample",
+			successes: 2,
+			total:     2,
+			expected:  stats.Interval{Lower: 0.3424, Upper: 1},
+		},
+		{
+			desc:      "half succeeded",
+			successes: 50,
+			total:     100,
+			expected:  stats.Interval{Lower: 0.4038, Upper: 0.5962},
+		},
+		{
+			desc:      "none succeeded",
+			successes: 0,
+			total:     10,
+			expected:  stats.Interval{Lower: 0, Upper: 0.2775},
+		},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var iv = stats.WilsonInterval(tc.successes, tc.total, stats.ConfidenceZ)
+			assert.InDelta(t, tc.expected.Lower, iv.Lower, 1e-4)
+			assert.InDelta(t, tc.expected.Upper, iv.Upper, 1e-4)
+		})
+	}
+}
+
+func TestCounts(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		var c = stats.Counts{}
+		assert.Equal(t, float32(0), c.RateSuccess())
+		assert.Equal(t, stats.Interval{Lower: 0, Upper: 1}, c.RateSuccessInterval())
+		assert.True(t, c.SmallSample())
+	})
+
+	t.Run("with builds", func(t *testing.T) {
+		var c = stats.Counts{Num: 40, NumFailed: 10}
+		assert.Equal(t, float32(0.75), c.RateSuccess())
+		assert.False(t, c.SmallSample())
+
+		var iv = c.RateSuccessInterval()
+		assert.True(t, iv.Lower < 0.75 && 0.75 < iv.Upper)
+	})
+}
@@ -0,0 +1,212 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// DedupPolicy decides which of the records with the same build ID is counted.
+type DedupPolicy uint8
+
+// The deduplication policies.
+const (
+	// DedupNone doesn't deduplicate the records.
+	DedupNone DedupPolicy = iota
+	// DedupFirstWins counts the first record of each build ID.
+	DedupFirstWins
+	// DedupLastWins counts the last record of each build ID.
+	DedupLastWins
+	// DedupError counts the first record of each build ID, like
+	// DedupFirstWins, but it fails on a duplicate whose fields differ from the
+	// ones of the counted record; all the fields are compared, so all of them
+	// must be of the expected format.
+	DedupError
+)
+
+// String returns the name of the policy, which ParseDedupPolicy accepts.
+func (p DedupPolicy) String() string {
+	switch p {
+	case DedupNone:
+		return "none"
+	case DedupFirstWins:
+		return "first"
+	case DedupLastWins:
+		return "last"
+	case DedupError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseDedupPolicy returns the policy whose String method returns name.
+func ParseDedupPolicy(name string) (DedupPolicy, error) {
+	for p := DedupNone; p <= DedupError; p++ {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+
+	return DedupNone, fmt.Errorf("Invalid deduplication policy %q. Valid ones are: none, first, last, error", name)
+}
+
+// ErrConflictingDuplicate is returned, wrapped with the build ID, with the
+// DedupError policy when two records of the same build have different fields.
+var ErrConflictingDuplicate = errors.New("Records with the same build ID have different fields")
+
+// DedupOptions configures the deduplication of the records by build ID.
+type DedupOptions struct {
+	Policy DedupPolicy
+	// ExpectedBuilds, when it isn't 0, bounds the memory used for remembering
+	// the seen build IDs with a Bloom filter sized for such number of builds,
+	// instead of keeping all of them. Then a record can be dropped as a
+	// duplicate with the FalsePositiveRate probability (1% by default) and
+	// only the DedupFirstWins policy can be used.
+	ExpectedBuilds    uint64
+	FalsePositiveRate float64
+}
+
+// DefaultFalsePositiveRate is the probability of dropping a unique record
+// with the Bloom filter when DedupOptions.FalsePositiveRate is 0.
+const DefaultFalsePositiveRate = 0.01
+
+// Deduplicator decides which records are counted when several of them have
+// the same build ID.
+type Deduplicator struct {
+	policy DedupPolicy
+	// seen contains the counted record of each build ID when the policy
+	// needs it to compare or discount it, otherwise nil.
+	seen    map[string]*Record
+	bloom   *bloomFilter
+	dropped uint64
+}
+
+// NewDeduplicator returns a Deduplicator configured by opts, whose Policy
+// cannot be DedupNone.
+func NewDeduplicator(opts DedupOptions) (*Deduplicator, error) {
+	if opts.Policy == DedupNone || opts.Policy > DedupError {
+		return nil, errors.New("Invalid argument. Policy must be first, last or error")
+	}
+
+	if opts.ExpectedBuilds == 0 {
+		return &Deduplicator{policy: opts.Policy, seen: map[string]*Record{}}, nil
+	}
+
+	if opts.Policy != DedupFirstWins {
+		return nil, errors.New("Invalid argument. The Bloom filter can only be used with the first policy")
+	}
+
+	var p = opts.FalsePositiveRate
+	if p == 0 {
+		p = DefaultFalsePositiveRate
+	}
+
+	if p < 0 || p >= 1 {
+		return nil, errors.New("Invalid argument. FalsePositiveRate must be between 0 and 1")
+	}
+
+	return &Deduplicator{policy: opts.Policy, bloom: newBloomFilter(opts.ExpectedBuilds, p)}, nil
+}
+
+// Check reports if rec must be counted and, with the DedupLastWins policy, the
+// previously counted record of the same build which must be discounted, if
+// any. It returns an error with the DedupError policy if rec conflicts with the
+// counted record of its build.
+func (d *Deduplicator) Check(rec *Record) (bool, *Record, error) {
+	if d.bloom != nil {
+		if d.bloom.testAndAdd(rec.BuildID) {
+			d.dropped++
+			return false, nil, nil
+		}
+
+		return true, nil, nil
+	}
+
+	var prev, ok = d.seen[rec.BuildID]
+	if !ok {
+		d.seen[rec.BuildID] = rec
+		return true, nil, nil
+	}
+
+	d.dropped++
+	switch d.policy {
+	case DedupLastWins:
+		d.seen[rec.BuildID] = rec
+		return true, prev, nil
+	case DedupError:
+		if !sameRecord(prev, rec) {
+			return false, nil, fmt.Errorf("%w: %s", ErrConflictingDuplicate, rec.BuildID)
+		}
+	}
+
+	return false, nil, nil
+}
+
+// Dropped returns the number of records which have been discarded for being
+// duplicates.
+func (d *Deduplicator) Dropped() uint64 {
+	return d.dropped
+}
+
+func sameRecord(a *Record, b *Record) bool {
+	return a.BuildID == b.BuildID && a.UserID == b.UserID &&
+		a.ReqTime.Equal(b.ReqTime) && a.ExecStart.Equal(b.ExecStart) && a.ExecEnd.Equal(b.ExecEnd) &&
+		a.Deleted == b.Deleted && a.ExitCode == b.ExitCode && a.ImageSize == b.ImageSize
+}
+
+// bloomFilter is a set of strings of a fixed size which can report that a
+// string is in it when it isn't.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+// newBloomFilter returns a bloomFilter sized for n strings with a false
+// positive probability of p.
+func newBloomFilter(n uint64, p float64) *bloomFilter {
+	var m = uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+
+	var k = uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// testAndAdd adds s to the set, reporting if it was already in it.
+func (bf *bloomFilter) testAndAdd(s string) bool {
+	var h = fnv.New128a()
+	_, _ = h.Write([]byte(s))
+
+	var (
+		sum    = h.Sum(nil)
+		h1, h2 uint64
+	)
+
+	for i := 0; i < 8; i++ {
+		h1 = h1<<8 | uint64(sum[i])
+		h2 = h2<<8 | uint64(sum[i+8])
+	}
+
+	var present = true
+	for i := uint64(0); i < bf.k; i++ {
+		var (
+			bit  = (h1 + i*h2) % bf.m
+			mask = uint64(1) << (bit % 64)
+		)
+
+		if bf.bits[bit/64]&mask == 0 {
+			present = false
+			bf.bits[bit/64] |= mask
+		}
+	}
+
+	return present
+}
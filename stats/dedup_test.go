@@ -0,0 +1,207 @@
+package stats_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDedupPolicy(t *testing.T) {
+	for _, p := range []stats.DedupPolicy{stats.DedupNone, stats.DedupFirstWins, stats.DedupLastWins, stats.DedupError} {
+		var parsed, err = stats.ParseDedupPolicy(p.String())
+		require.NoError(t, err)
+		assert.Equal(t, p, parsed)
+	}
+
+	var _, err = stats.ParseDedupPolicy("random")
+	assert.Error(t, err)
+}
+
+func TestComputeBuildsContext_Dedup(t *testing.T) {
+	var (
+		from = time.Date(2018, 10, 31, 0, 0, 0, 0, time.UTC)
+		to   = time.Date(2018, 11, 2, 0, 0, 0, 0, time.UTC)
+		// b2 is exported twice and b1 is exported again with a different exit
+		// code.
+		in = strings.Join(append(groupRecords,
+			groupRecords[1],
+			"b1,userA,2018-10-31T10:00:00-04:00,2018-10-31T10:01:00-04:00,2018-10-31T10:11:00-04:00,false,5,50000000",
+		), "\n")
+	)
+
+	var tcases = []struct {
+		desc       string
+		opts       stats.DedupOptions
+		num        uint64
+		numFailed  uint64
+		errCodes   map[uint8]uint64
+		duplicates uint64
+		// groups is the number of builds of each exit code group.
+		groups map[string]uint64
+	}{
+		{
+			desc:      "none",
+			opts:      stats.DedupOptions{},
+			num:       7,
+			numFailed: 4,
+			errCodes:  map[uint8]uint64{2: 2, 3: 1, 5: 1},
+			groups:    map[string]uint64{"0": 3, "2": 2, "3": 1, "5": 1},
+		},
+		{
+			desc:       "first wins",
+			opts:       stats.DedupOptions{Policy: stats.DedupFirstWins},
+			num:        5,
+			numFailed:  2,
+			errCodes:   map[uint8]uint64{2: 1, 3: 1},
+			duplicates: 2,
+			groups:     map[string]uint64{"0": 3, "2": 1, "3": 1},
+		},
+		{
+			desc:       "last wins",
+			opts:       stats.DedupOptions{Policy: stats.DedupLastWins},
+			num:        5,
+			numFailed:  3,
+			errCodes:   map[uint8]uint64{2: 1, 3: 1, 5: 1},
+			duplicates: 2,
+			groups:     map[string]uint64{"0": 2, "2": 1, "3": 1, "5": 1},
+		},
+		{
+			desc:       "first wins with a Bloom filter",
+			opts:       stats.DedupOptions{Policy: stats.DedupFirstWins, ExpectedBuilds: 100, FalsePositiveRate: 0.0001},
+			num:        5,
+			numFailed:  2,
+			errCodes:   map[uint8]uint64{2: 1, 3: 1},
+			duplicates: 2,
+			groups:     map[string]uint64{"0": 3, "2": 1, "3": 1},
+		},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var b, err = stats.ComputeBuildsContext(
+				t.Context(), csv.NewReader(strings.NewReader(in)), from, to,
+				stats.Options{Dedup: tc.opts, GroupBy: "exit-code"},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, tc.num, b.Num)
+			assert.Equal(t, tc.numFailed, b.NumFailed)
+			assert.Equal(t, tc.errCodes, b.ErrCodes)
+			assert.Equal(t, tc.duplicates, b.NumDuplicates)
+
+			var groups = map[string]uint64{}
+			for _, g := range b.Groups {
+				groups[g.Key] = g.Counts.Num
+			}
+			assert.Equal(t, tc.groups, groups)
+		})
+	}
+
+	t.Run("error: conflicting duplicate", func(t *testing.T) {
+		var _, err = stats.ComputeBuildsContext(
+			t.Context(), csv.NewReader(strings.NewReader(in)), from, to,
+			stats.Options{Dedup: stats.DedupOptions{Policy: stats.DedupError}},
+		)
+		assert.True(t, errors.Is(err, stats.ErrConflictingDuplicate))
+		assert.Contains(t, err.Error(), "b1")
+	})
+
+	t.Run("error: duplicate differing in fields unused by the stats", func(t *testing.T) {
+		// The record of b2 exported again with other request and execution
+		// start times, deleted flag and image size.
+		var fields = strings.Split(groupRecords[1], ",")
+		fields[2], fields[3] = "2018-10-31T09:00:00-04:00", "2018-10-31T09:01:00-04:00"
+		fields[5], fields[7] = "false", "1"
+
+		var in = strings.Join(append(groupRecords, strings.Join(fields, ",")), "\n")
+		var _, err = stats.ComputeBuildsContext(
+			t.Context(), csv.NewReader(strings.NewReader(in)), from, to,
+			stats.Options{Dedup: stats.DedupOptions{Policy: stats.DedupError}},
+		)
+		assert.True(t, errors.Is(err, stats.ErrConflictingDuplicate))
+		assert.Contains(t, err.Error(), "b2")
+	})
+
+	t.Run("error policy with identical duplicates", func(t *testing.T) {
+		var in = strings.Join(append(groupRecords, groupRecords[1], groupRecords[3]), "\n")
+		var b, err = stats.ComputeBuildsContext(
+			t.Context(), csv.NewReader(strings.NewReader(in)), from, to,
+			stats.Options{Dedup: stats.DedupOptions{Policy: stats.DedupError}},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(5), b.Num)
+		assert.Equal(t, uint64(2), b.NumDuplicates)
+	})
+
+	t.Run("error: checkpoints", func(t *testing.T) {
+		var _, err = stats.ComputeBuildsContext(
+			t.Context(), csv.NewReader(strings.NewReader(in)), from, to,
+			stats.Options{
+				Dedup:      stats.DedupOptions{Policy: stats.DedupFirstWins},
+				Checkpoint: func(*stats.Checkpoint) error { return nil },
+			},
+		)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewDeduplicator(t *testing.T) {
+	var tcases = []struct {
+		desc string
+		opts stats.DedupOptions
+	}{
+		{desc: "none", opts: stats.DedupOptions{}},
+		{desc: "Bloom filter with last wins", opts: stats.DedupOptions{Policy: stats.DedupLastWins, ExpectedBuilds: 10}},
+		{desc: "Bloom filter with error", opts: stats.DedupOptions{Policy: stats.DedupError, ExpectedBuilds: 10}},
+		{
+			desc: "invalid false positive rate",
+			opts: stats.DedupOptions{Policy: stats.DedupFirstWins, ExpectedBuilds: 10, FalsePositiveRate: 1},
+		},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var _, err = stats.NewDeduplicator(tc.opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDeduplicator_Bloom(t *testing.T) {
+	const n = 10000
+	var dd, err = stats.NewDeduplicator(stats.DedupOptions{Policy: stats.DedupFirstWins, ExpectedBuilds: n})
+	require.NoError(t, err)
+
+	var added int
+	for i := 0; i < n; i++ {
+		var add, replaced, err = dd.Check(&stats.Record{BuildID: fmt.Sprintf("build-%d", i)})
+		require.NoError(t, err)
+		assert.Nil(t, replaced)
+		if add {
+			added++
+		}
+	}
+
+	// Around 1% of the unique builds are dropped by false positives.
+	assert.True(t, added > n*98/100, "added: %d", added)
+
+	for i := 0; i < n; i++ {
+		var add, _, err = dd.Check(&stats.Record{BuildID: fmt.Sprintf("build-%d", i)})
+		require.NoError(t, err)
+		assert.False(t, add)
+	}
+
+	assert.Equal(t, uint64(2*n-added), dd.Dropped())
+}
@@ -0,0 +1,315 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Distribution is a probability distribution of the values of a field of the
+// synthetic records, see GeneratorOptions.
+type Distribution interface {
+	// Sample returns a value drawn from the distribution with r.
+	Sample(r *rand.Rand) float64
+}
+
+// Constant is a Distribution which always returns its value.
+type Constant float64
+
+// Sample returns c.
+func (c Constant) Sample(*rand.Rand) float64 {
+	return float64(c)
+}
+
+// Uniform is a Distribution whose values are equally likely between Min and
+// Max.
+type Uniform struct {
+	Min float64
+	Max float64
+}
+
+// Sample returns a value between u.Min and u.Max.
+func (u Uniform) Sample(r *rand.Rand) float64 {
+	return u.Min + r.Float64()*(u.Max-u.Min)
+}
+
+// Exponential is a Distribution of the time between events which happen at a
+// constant rate, with Mean as mean.
+type Exponential struct {
+	Mean float64
+}
+
+// Sample returns a value of the exponential distribution of mean e.Mean.
+func (e Exponential) Sample(r *rand.Rand) float64 {
+	return r.ExpFloat64() * e.Mean
+}
+
+// LogNormal is a Distribution whose logarithm is normally distributed; it
+// models well the durations and sizes, which are mostly around Median with a
+// long tail of large values whose spread grows with Sigma.
+type LogNormal struct {
+	Median float64
+	Sigma  float64
+}
+
+// Sample returns a value of the log-normal distribution of l.
+func (l LogNormal) Sample(r *rand.Rand) float64 {
+	return l.Median * math.Exp(r.NormFloat64()*l.Sigma)
+}
+
+// ParseDistribution parses s, which is one of: const:V, uniform:MIN:MAX,
+// exp:MEAN or lognormal:MEDIAN:SIGMA. The values, except SIGMA, are parsed by
+// parseValue.
+func ParseDistribution(s string, parseValue func(string) (float64, error)) (Distribution, error) {
+	var (
+		parts = strings.Split(s, ":")
+		args  = make([]float64, len(parts)-1)
+		err   error
+	)
+
+	for i, p := range parts[1:] {
+		if parts[0] == "lognormal" && i == 1 {
+			args[i], err = strconv.ParseFloat(p, 64)
+		} else {
+			args[i], err = parseValue(p)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid distribution %q: %s", s, err.Error())
+		}
+	}
+
+	var arity = map[string]int{"const": 1, "uniform": 2, "exp": 1, "lognormal": 2}
+	if n, ok := arity[parts[0]]; !ok || n != len(args) {
+		return nil, fmt.Errorf(
+			"Invalid distribution %q. It must be one of: const:V, uniform:MIN:MAX, exp:MEAN, lognormal:MEDIAN:SIGMA", s,
+		)
+	}
+
+	switch parts[0] {
+	case "const":
+		return Constant(args[0]), nil
+	case "uniform":
+		return Uniform{Min: args[0], Max: args[1]}, nil
+	case "exp":
+		return Exponential{Mean: args[0]}, nil
+	default:
+		return LogNormal{Median: args[0], Sigma: args[1]}, nil
+	}
+}
+
+// DefaultExitCodes are the weights of the exit codes of the synthetic records
+// when GeneratorOptions.ExitCodes is empty.
+var DefaultExitCodes = map[uint8]float64{0: 90, 1: 5, 2: 2, 125: 1, 137: 1, 143: 1}
+
+// GeneratorOptions configures the synthetic records of a Generator. The zero
+// values of the fields, except the time range and Records, are replaced by the
+// defaults indicated in each one.
+type GeneratorOptions struct {
+	// From and To are the range, which includes From but not To, of the
+	// request times; the executions may finish after To.
+	From time.Time
+	To   time.Time
+	// Records is the number of records.
+	Records uint64
+	// Seed initializes the pseudo-random generator, so the same options always
+	// generate the same records.
+	Seed int64
+	// Users is the number of users (1000 by default), whose number of builds
+	// follows a Zipf distribution whose exponent is UserSkew (1.2 by default),
+	// which must be greater than 1; the greater it is, the more builds the
+	// most active users have.
+	Users    uint64
+	UserSkew float64
+	// ExitCodes contains the weight of each exit code (DefaultExitCodes by
+	// default).
+	ExitCodes map[uint8]float64
+	// QueueWait is the distribution, in seconds, of the time between the
+	// request and the execution start (log-normal of median 20 and sigma 1 by
+	// default), Duration the one, in seconds, of the execution (log-normal of
+	// median 600 and sigma 0.8 by default) and ImageSize the one, in bytes, of
+	// the image size (log-normal of median 200MB and sigma 1 by default). The
+	// negative values are 0.
+	QueueWait Distribution
+	Duration  Distribution
+	ImageSize Distribution
+	// DeletedRate is the probability of a build to be deleted.
+	DeletedRate float64
+	// Location is the one of the times of the records (UTC by default).
+	Location *time.Location
+}
+
+// Generator generates synthetic records of the remote build service CSV
+// exports, sorted by the request time, without keeping them in memory.
+type Generator struct {
+	opts  GeneratorOptions
+	rng   *rand.Rand
+	users *rand.Zipf
+	// codes are the exit codes and cumWeights the sum of the weights of the
+	// exit codes up to each one.
+	codes      []uint8
+	cumWeights []float64
+	// buildPrefix and userPrefix start the IDs of the builds and the users, so
+	// different seeds generate different IDs.
+	buildPrefix uint32
+	userPrefix  uint32
+	// pos is the position of the last request time in the time range, between
+	// 0 and 1, and n the number of generated records.
+	pos float64
+	n   uint64
+}
+
+// NewGenerator returns a Generator of the records indicated by opts.
+func NewGenerator(opts GeneratorOptions) (*Generator, error) {
+	if !opts.From.Before(opts.To) {
+		return nil, errors.New("Invalid argument. 'From' must be previous to 'To'")
+	}
+
+	if opts.Users == 0 {
+		opts.Users = 1000
+	}
+
+	if opts.UserSkew == 0 {
+		opts.UserSkew = 1.2
+	}
+
+	if opts.UserSkew <= 1 {
+		return nil, errors.New("Invalid argument. UserSkew must be greater than 1")
+	}
+
+	if len(opts.ExitCodes) == 0 {
+		opts.ExitCodes = DefaultExitCodes
+	}
+
+	if opts.QueueWait == nil {
+		opts.QueueWait = LogNormal{Median: 20, Sigma: 1}
+	}
+
+	if opts.Duration == nil {
+		opts.Duration = LogNormal{Median: 600, Sigma: 0.8}
+	}
+
+	if opts.ImageSize == nil {
+		opts.ImageSize = LogNormal{Median: 200e6, Sigma: 1}
+	}
+
+	if opts.DeletedRate < 0 || opts.DeletedRate > 1 {
+		return nil, errors.New("Invalid argument. DeletedRate must be between 0 and 1")
+	}
+
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	var g = &Generator{opts: opts, rng: rand.New(rand.NewSource(opts.Seed))}
+	g.users = rand.NewZipf(g.rng, opts.UserSkew, 1, opts.Users-1)
+	g.buildPrefix, g.userPrefix = g.rng.Uint32(), g.rng.Uint32()
+
+	for c := range opts.ExitCodes {
+		g.codes = append(g.codes, c)
+	}
+	sort.Slice(g.codes, func(i, j int) bool { return g.codes[i] < g.codes[j] })
+
+	var sum float64
+	for _, c := range g.codes {
+		var w = opts.ExitCodes[c]
+		if w < 0 {
+			return nil, fmt.Errorf("Invalid argument. The weight of the exit code %d is negative", c)
+		}
+
+		sum += w
+		g.cumWeights = append(g.cumWeights, sum)
+	}
+
+	if sum == 0 {
+		return nil, errors.New("Invalid argument. The exit codes weights cannot be all 0")
+	}
+
+	return g, nil
+}
+
+// Next returns the next record and true, or nil and false when all the records
+// have been generated.
+func (g *Generator) Next() (*Record, bool) {
+	if g.n == g.opts.Records {
+		return nil, false
+	}
+
+	// The next of n uniform values between pos and 1 sorted in ascending
+	// order is the minimum of them.
+	var remaining = float64(g.opts.Records - g.n)
+	g.pos += (1 - g.pos) * (1 - math.Pow(g.rng.Float64(), 1/remaining))
+	g.n++
+
+	var (
+		span  = g.opts.To.Sub(g.opts.From)
+		req   = g.opts.From.Add(time.Duration(g.pos * float64(span))).Truncate(time.Second)
+		start = req.Add(seconds(g.opts.QueueWait.Sample(g.rng)))
+		end   = start.Add(seconds(g.opts.Duration.Sample(g.rng)))
+		code  = g.codes[sort.SearchFloat64s(g.cumWeights, g.rng.Float64()*g.cumWeights[len(g.cumWeights)-1])]
+	)
+
+	// pos is never 1, but the conversion to time.Duration may round it up.
+	if !req.Before(g.opts.To) {
+		req = g.opts.To.Add(-time.Second).Truncate(time.Second)
+	}
+
+	return &Record{
+		BuildID:   fmt.Sprintf("%08x%016x", g.buildPrefix, g.n),
+		UserID:    fmt.Sprintf("%08x%016x", g.userPrefix, g.users.Uint64()),
+		ReqTime:   req.In(g.opts.Location),
+		ExecStart: start.In(g.opts.Location),
+		ExecEnd:   end.In(g.opts.Location),
+		Deleted:   g.rng.Float64() < g.opts.DeletedRate,
+		ExitCode:  code,
+		ImageSize: int64(math.Max(0, g.opts.ImageSize.Sample(g.rng))),
+	}, true
+}
+
+// seconds returns the duration of s seconds, truncated to seconds, or 0 if
+// it's negative.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(0, math.Floor(s))) * time.Second
+}
+
+// Generate writes to w, as CSV, the records generated by a Generator with
+// opts and returns the number of written records.
+func Generate(w io.Writer, opts GeneratorOptions) (uint64, error) {
+	var g, err = NewGenerator(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		bw  = bufio.NewWriterSize(w, 1<<16)
+		cw  = csv.NewWriter(bw)
+		n   uint64
+		rec = make([]string, NumFields)
+	)
+
+	for r, ok := g.Next(); ok; r, ok = g.Next() {
+		rec[0], rec[1] = r.BuildID, r.UserID
+		rec[2], rec[3], rec[4] = r.ReqTime.Format(time.RFC3339), r.ExecStart.Format(time.RFC3339), r.ExecEnd.Format(time.RFC3339)
+		rec[5], rec[6], rec[7] = strconv.FormatBool(r.Deleted), strconv.Itoa(int(r.ExitCode)), strconv.FormatInt(r.ImageSize, 10)
+		if err := cw.Write(rec); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return n, err
+	}
+
+	return n, bw.Flush()
+}
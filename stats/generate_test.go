@@ -0,0 +1,169 @@
+package stats_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDistribution(t *testing.T) {
+	var parseFloat = func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
+	}
+
+	var tcases = []struct {
+		desc     string
+		in       string
+		expected stats.Distribution
+	}{
+		{desc: "constant", in: "const:5", expected: stats.Constant(5)},
+		{desc: "uniform", in: "uniform:1:10", expected: stats.Uniform{Min: 1, Max: 10}},
+		{desc: "exponential", in: "exp:30", expected: stats.Exponential{Mean: 30}},
+		{desc: "log-normal", in: "lognormal:600:0.8", expected: stats.LogNormal{Median: 600, Sigma: 0.8}},
+		{desc: "unknown", in: "normal:5:1"},
+		{desc: "missing value", in: "uniform:1"},
+		{desc: "invalid value", in: "exp:soon"},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var d, err = stats.ParseDistribution(tc.in, parseFloat)
+			if tc.expected == nil {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var opts = stats.GeneratorOptions{
+		From:    time.Date(2018, 10, 31, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2018, 11, 2, 0, 0, 0, 0, time.UTC),
+		Records: 5000,
+		Seed:    7,
+		Users:   50,
+	}
+
+	var generate = func(opts stats.GeneratorOptions) []byte {
+		var buf bytes.Buffer
+		var n, err = stats.Generate(&buf, opts)
+		require.NoError(t, err)
+		assert.Equal(t, opts.Records, n)
+		return buf.Bytes()
+	}
+
+	var out = generate(opts)
+	assert.Equal(t, out, generate(opts), "same seed")
+
+	var other = opts
+	other.Seed = 8
+	assert.NotEqual(t, out, generate(other), "different seed")
+
+	t.Run("valid", func(t *testing.T) {
+		var so = stats.SemanticOptions{Actions: map[stats.Rule]stats.Action{}, Now: opts.To.Add(24 * time.Hour)}
+		for _, r := range stats.SemanticRules {
+			so.Actions[r] = stats.ActionReject
+		}
+
+		var s, err = stats.Validate(csv.NewReader(bytes.NewReader(out)), so, func(v stats.Violation) {
+			t.Errorf("unexpected violation: %+v", v)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, opts.Records, s.Records)
+	})
+
+	t.Run("time range and users", func(t *testing.T) {
+		var b, err = stats.ComputeBuilds(csv.NewReader(bytes.NewReader(out)), opts.From, opts.To.Add(48*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, opts.Records, b.Num)
+		assert.True(t, len(b.Users) <= int(opts.Users))
+
+		// The most active user has many more builds than an evenly distributed
+		// one.
+		var users = b.RankedUsers()
+		assert.True(t, users[0].Num > 5*opts.Records/opts.Users)
+
+		g, err := stats.NewGenerator(opts)
+		require.NoError(t, err)
+		var last time.Time
+		for r, ok := g.Next(); ok; r, ok = g.Next() {
+			assert.False(t, r.ReqTime.Before(last))
+			assert.False(t, r.ReqTime.Before(opts.From))
+			assert.True(t, r.ReqTime.Before(opts.To))
+			last = r.ReqTime
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		// All the exit codes, including the ones of the builds killed by a
+		// signal, must be read back by the stats.
+		var o = opts
+		o.ExitCodes = map[uint8]float64{0: 1, 1: 1, 127: 1, 128: 1, 137: 1, 255: 1}
+
+		var g, err = stats.NewGenerator(o)
+		require.NoError(t, err)
+		var expected = map[uint8]uint64{}
+		for r, ok := g.Next(); ok; r, ok = g.Next() {
+			if r.ExitCode != 0 {
+				expected[r.ExitCode]++
+			}
+		}
+
+		b, err := stats.ComputeBuilds(csv.NewReader(bytes.NewReader(generate(o))), o.From, o.To.Add(48*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, o.Records, b.Num)
+		assert.Equal(t, expected, b.ErrCodes)
+		assert.Len(t, b.ErrCodes, 5)
+	})
+
+	t.Run("distributions", func(t *testing.T) {
+		var o = opts
+		o.Records = 100
+		o.ExitCodes = map[uint8]float64{3: 1, 4: 0}
+		o.QueueWait, o.Duration, o.ImageSize = stats.Constant(30), stats.Constant(600), stats.Uniform{Min: -2, Max: -1}
+		o.DeletedRate = 1
+
+		var g, err = stats.NewGenerator(o)
+		require.NoError(t, err)
+		for r, ok := g.Next(); ok; r, ok = g.Next() {
+			assert.Equal(t, 30*time.Second, r.ExecStart.Sub(r.ReqTime))
+			assert.Equal(t, 10*time.Minute, r.Duration())
+			assert.Equal(t, uint8(3), r.ExitCode)
+			assert.Equal(t, int64(0), r.ImageSize)
+			assert.True(t, r.Deleted)
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		var tcases = []struct {
+			desc   string
+			modify func(o *stats.GeneratorOptions)
+		}{
+			{desc: "empty time range", modify: func(o *stats.GeneratorOptions) { o.To = o.From }},
+			{desc: "user skew", modify: func(o *stats.GeneratorOptions) { o.UserSkew = 0.5 }},
+			{desc: "deleted rate", modify: func(o *stats.GeneratorOptions) { o.DeletedRate = 2 }},
+			{desc: "negative weight", modify: func(o *stats.GeneratorOptions) { o.ExitCodes = map[uint8]float64{0: -1} }},
+			{desc: "zero weights", modify: func(o *stats.GeneratorOptions) { o.ExitCodes = map[uint8]float64{0: 0} }},
+		}
+
+		for _, tc := range tcases {
+			var o = opts
+			tc.modify(&o)
+			var _, err = stats.NewGenerator(o)
+			assert.Error(t, err, tc.desc)
+		}
+	})
+}
@@ -0,0 +1,343 @@
+package stats
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dimension returns the key of the group which rec belongs to and the order of
+// such group; groups are sorted by order and then by key.
+// Any function which derives a key from the record fields can be used as a
+// Dimension.
+type Dimension func(rec *Record) (key string, order int)
+
+// ByUser groups the records by user ID.
+func ByUser(rec *Record) (string, int) {
+	return rec.UserID, 0
+}
+
+// ByExitCode groups the records by exit code.
+func ByExitCode(rec *Record) (string, int) {
+	return strconv.Itoa(int(rec.ExitCode)), int(rec.ExitCode)
+}
+
+// ByHour groups the records by the hour of the day of their execution finish
+// time.
+func ByHour(rec *Record) (string, int) {
+	var h = rec.ExecEnd.Hour()
+	return fmt.Sprintf("%02d", h), h
+}
+
+// ByWeekday groups the records by the day of the week of their execution
+// finish time.
+func ByWeekday(rec *Record) (string, int) {
+	var d = rec.ExecEnd.Weekday()
+	return d.String(), int(d)
+}
+
+// ByDeleted groups the records by their deleted indicator.
+func ByDeleted(rec *Record) (string, int) {
+	return strconv.FormatBool(rec.Deleted), 0
+}
+
+// InLocation returns a Dimension which calls d with the times of the record
+// converted to loc, so the dimensions based on the hour or the day, like
+// ByHour and ByWeekday, group the records in such location.
+func InLocation(d Dimension, loc *time.Location) Dimension {
+	return func(rec *Record) (string, int) {
+		var r = *rec
+		r.ReqTime = r.ReqTime.In(loc)
+		r.ExecStart = r.ExecStart.In(loc)
+		r.ExecEnd = r.ExecEnd.In(loc)
+
+		return d(&r)
+	}
+}
+
+// DefaultSizeBuckets are the image size bucket limits, in bytes, of the
+// "size" Dimension returned by DimensionByName.
+var DefaultSizeBuckets = []int64{100e6, 500e6, 1e9}
+
+// BySizeBucket returns a Dimension which groups the records by the image size
+// in the buckets delimited by limits, which must be sorted in ascending order.
+// Each bucket includes its lower limit and excludes its upper one.
+func BySizeBucket(limits ...int64) Dimension {
+	return func(rec *Record) (string, int) {
+		var i = sort.Search(len(limits), func(i int) bool {
+			return rec.ImageSize < limits[i]
+		})
+
+		switch {
+		case len(limits) == 0:
+			return "any", 0
+		case i == 0:
+			return "< " + FormatSize(limits[0]), i
+		case i == len(limits):
+			return ">= " + FormatSize(limits[i-1]), i
+		default:
+			return FormatSize(limits[i-1]) + " - " + FormatSize(limits[i]), i
+		}
+	}
+}
+
+// DimensionNames are the names accepted by DimensionByName.
+var DimensionNames = []string{"user", "exit-code", "hour", "weekday", "deleted", "size"}
+
+// DimensionByName returns the Dimension identified by name, which must be one
+// of DimensionNames.
+func DimensionByName(name string) (Dimension, error) {
+	switch name {
+	case "user":
+		return ByUser, nil
+	case "exit-code":
+		return ByExitCode, nil
+	case "hour":
+		return ByHour, nil
+	case "weekday":
+		return ByWeekday, nil
+	case "deleted":
+		return ByDeleted, nil
+	case "size":
+		return BySizeBucket(DefaultSizeBuckets...), nil
+	default:
+		return nil, fmt.Errorf("Invalid dimension %q. Valid ones are: %s", name, strings.Join(DimensionNames, ", "))
+	}
+}
+
+// dimensionIn returns the Dimension whose name is name, see DimensionByName,
+// with the times of the records in loc (UTC if it's nil).
+func dimensionIn(name string, loc *time.Location) (Dimension, error) {
+	var d, err = DimensionByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	return InLocation(d, loc), nil
+}
+
+// FormatSize returns size, in bytes, formatted with the biggest decimal unit
+// (KB, MB, GB, TB) which keeps its integer part greater than 0, and rounded to
+// 2 decimals.
+func FormatSize(size int64) string {
+	var units = []string{"B", "KB", "MB", "GB", "TB"}
+
+	var (
+		v = float64(size)
+		u = 0
+	)
+	for ; u < len(units)-1 && (v >= 1000 || v <= -1000); u++ {
+		v /= 1000
+	}
+
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64) + units[u]
+}
+
+// DurationStats contains the statistics of a set of build durations.
+type DurationStats struct {
+	Num   uint64        `json:"num"`
+	Min   time.Duration `json:"min"`
+	Max   time.Duration `json:"max"`
+	Total time.Duration `json:"total"`
+}
+
+// Add adds d to the set.
+func (ds *DurationStats) Add(d time.Duration) {
+	if ds.Num == 0 || d < ds.Min {
+		ds.Min = d
+	}
+
+	if ds.Num == 0 || d > ds.Max {
+		ds.Max = d
+	}
+
+	ds.Num++
+	ds.Total += d
+}
+
+// Mean returns the average duration; it's 0 if the set is empty.
+func (ds DurationStats) Mean() time.Duration {
+	if ds.Num == 0 {
+		return 0
+	}
+
+	return ds.Total / time.Duration(ds.Num)
+}
+
+// Group contains the stats of the builds which belong to the same group of a
+// Dimension.
+type Group struct {
+	Key       string        `json:"key"`
+	Counts    Counts        `json:"counts"`
+	Durations DurationStats `json:"durations"`
+}
+
+// ComputeGroups calculate the stats of each group of d of r records pending to
+// read considering the passed time window.
+// Records must have all their fields of the expected format, see
+// NewFullRecordFromCSV.
+func ComputeGroups(r *csv.Reader, from time.Time, to time.Time, d Dimension) ([]Group, error) {
+	return ComputeGroupsWith(r, from, to, d, GroupOptions{})
+}
+
+// GroupOptions contains the optional settings of ComputeGroupsWith: the
+// included limits of the time window and the rules checked on its records.
+type GroupOptions struct {
+	Bounds    Bounds
+	Semantics SemanticOptions
+}
+
+// ComputeGroupsWith is like ComputeGroups but it accepts opts.
+func ComputeGroupsWith(r *csv.Reader, from time.Time, to time.Time, d Dimension, opts GroupOptions) ([]Group, error) {
+	if d == nil {
+		return nil, errors.New("Invalid argument. Dimension cannot be nil")
+	}
+
+	var twr, err = NewTimeWindowReaderBounds(r, from, to, opts.Bounds)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		csvr []string
+		gs   = newGroupSet(d)
+		sf   = newSemanticFilter(r, opts.Semantics)
+	)
+
+	for csvr, err = twr.Read(); err == nil; csvr, err = twr.Read() {
+		var (
+			rec    *Record
+			accept bool
+		)
+
+		rec, accept, err = sf.parse(csvr, NewFullRecordFromCSV)
+		if err != nil {
+			break
+		}
+
+		if !accept {
+			continue
+		}
+
+		gs.add(rec)
+	}
+
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return gs.sorted(), nil
+}
+
+// groupSet accumulates the records in the groups of a Dimension.
+type groupSet struct {
+	d      Dimension
+	groups []Group
+	orders []int
+	idxs   map[string]int
+}
+
+func newGroupSet(d Dimension) *groupSet {
+	return &groupSet{d: d, idxs: map[string]int{}}
+}
+
+func (gs *groupSet) add(rec *Record) {
+	var k, o = gs.d(rec)
+	var i, ok = gs.idxs[k]
+	if !ok {
+		i = len(gs.groups)
+		gs.idxs[k] = i
+		gs.groups = append(gs.groups, Group{Key: k})
+		gs.orders = append(gs.orders, o)
+	}
+
+	var g = &gs.groups[i]
+	g.Counts.Num++
+	if rec.ExitCode > 0 {
+		g.Counts.NumFailed++
+	}
+
+	if !rec.ExecStart.IsZero() {
+		g.Durations.Add(rec.Duration())
+	}
+}
+
+// clone returns a copy of gs which doesn't share any state with it.
+func (gs *groupSet) clone() *groupSet {
+	var c = &groupSet{
+		d:      gs.d,
+		groups: append([]Group(nil), gs.groups...),
+		orders: append([]int(nil), gs.orders...),
+		idxs:   make(map[string]int, len(gs.idxs)),
+	}
+
+	for k, i := range gs.idxs {
+		c.idxs[k] = i
+	}
+
+	return c
+}
+
+// groupState is the serialized representation of a group of a groupSet.
+type groupState struct {
+	Group
+	Order int `json:"order"`
+}
+
+// states returns the serialized representation of the groups of gs.
+func (gs *groupSet) states() []groupState {
+	var states = make([]groupState, len(gs.groups))
+	for i, g := range gs.groups {
+		states[i] = groupState{Group: g, Order: gs.orders[i]}
+	}
+
+	return states
+}
+
+// restore adds the groups of states, which must not be in gs.
+func (gs *groupSet) restore(states []groupState) {
+	for _, st := range states {
+		gs.idxs[st.Key] = len(gs.groups)
+		gs.groups = append(gs.groups, st.Group)
+		gs.orders = append(gs.orders, st.Order)
+	}
+}
+
+// sorted returns the groups sorted by the order of their keys; gs cannot be
+// used after calling it.
+func (gs *groupSet) sorted() []Group {
+	sort.Sort(groupsByOrder{groups: gs.groups, orders: gs.orders})
+	return gs.groups
+}
+
+type groupsByOrder struct {
+	groups []Group
+	orders []int
+}
+
+func (g groupsByOrder) Len() int {
+	return len(g.groups)
+}
+
+func (g groupsByOrder) Less(i, j int) bool {
+	if g.orders[i] != g.orders[j] {
+		return g.orders[i] < g.orders[j]
+	}
+
+	return g.groups[i].Key < g.groups[j].Key
+}
+
+func (g groupsByOrder) Swap(i, j int) {
+	g.groups[i], g.groups[j] = g.groups[j], g.groups[i]
+	g.orders[i], g.orders[j] = g.orders[j], g.orders[i]
+}
@@ -0,0 +1,248 @@
+package stats_test
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var groupRecords = []string{
+	"b1,userA,2018-10-31T10:00:00-04:00,2018-10-31T10:01:00-04:00,2018-10-31T10:11:00-04:00,false,0,50000000",
+	"b2,userA,2018-10-31T10:00:00-04:00,2018-10-31T10:01:00-04:00,2018-10-31T10:31:00-04:00,true,2,150000000",
+	"b3,userB,2018-10-31T11:00:00-04:00,2018-10-31T11:00:00-04:00,2018-10-31T11:20:00-04:00,false,0,2000000000",
+	"b4,userB,2018-11-01T09:00:00-04:00,2018-11-01T09:00:00-04:00,2018-11-01T09:05:00-04:00,false,0,600000000",
+	"b5,userC,2018-11-01T09:00:00-04:00,2018-11-01T09:00:00-04:00,2018-11-01T09:15:00-04:00,false,3,60000000",
+}
+
+func TestComputeGroups(t *testing.T) {
+	var tcases = []struct {
+		desc     string
+		dim      string
+		expected []stats.Group
+	}{
+		{
+			desc: "user",
+			dim:  "user",
+			expected: []stats.Group{
+				{
+					Key:    "userA",
+					Counts: stats.Counts{Num: 2, NumFailed: 1},
+					Durations: stats.DurationStats{
+						Num: 2, Min: 10 * time.Minute, Max: 30 * time.Minute, Total: 40 * time.Minute,
+					},
+				},
+				{
+					Key:    "userB",
+					Counts: stats.Counts{Num: 2},
+					Durations: stats.DurationStats{
+						Num: 2, Min: 5 * time.Minute, Max: 20 * time.Minute, Total: 25 * time.Minute,
+					},
+				},
+				{
+					Key:    "userC",
+					Counts: stats.Counts{Num: 1, NumFailed: 1},
+					Durations: stats.DurationStats{
+						Num: 1, Min: 15 * time.Minute, Max: 15 * time.Minute, Total: 15 * time.Minute,
+					},
+				},
+			},
+		},
+		{
+			desc: "weekday",
+			dim:  "weekday",
+			expected: []stats.Group{
+				{
+					Key:    "Wednesday",
+					Counts: stats.Counts{Num: 3, NumFailed: 1},
+					Durations: stats.DurationStats{
+						Num: 3, Min: 10 * time.Minute, Max: 30 * time.Minute, Total: 60 * time.Minute,
+					},
+				},
+				{
+					Key:    "Thursday",
+					Counts: stats.Counts{Num: 2, NumFailed: 1},
+					Durations: stats.DurationStats{
+						Num: 2, Min: 5 * time.Minute, Max: 15 * time.Minute, Total: 20 * time.Minute,
+					},
+				},
+			},
+		},
+		{
+			desc: "size",
+			dim:  "size",
+			expected: []stats.Group{
+				{
+					Key:    "< 100MB",
+					Counts: stats.Counts{Num: 2, NumFailed: 1},
+					Durations: stats.DurationStats{
+						Num: 2, Min: 10 * time.Minute, Max: 15 * time.Minute, Total: 25 * time.Minute,
+					},
+				},
+				{
+					Key:    "100MB - 500MB",
+					Counts: stats.Counts{Num: 1, NumFailed: 1},
+					Durations: stats.DurationStats{
+						Num: 1, Min: 30 * time.Minute, Max: 30 * time.Minute, Total: 30 * time.Minute,
+					},
+				},
+				{
+					Key:    "500MB - 1GB",
+					Counts: stats.Counts{Num: 1},
+					Durations: stats.DurationStats{
+						Num: 1, Min: 5 * time.Minute, Max: 5 * time.Minute, Total: 5 * time.Minute,
+					},
+				},
+				{
+					Key:    ">= 1GB",
+					Counts: stats.Counts{Num: 1},
+					Durations: stats.DurationStats{
+						Num: 1, Min: 20 * time.Minute, Max: 20 * time.Minute, Total: 20 * time.Minute,
+					},
+				},
+			},
+		},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var d, err = stats.DimensionByName(tc.dim)
+			require.NoError(t, err)
+
+			var in = strings.NewReader(strings.Join(groupRecords, "\n"))
+			g, err := stats.ComputeGroups(csv.NewReader(in), expectedBuilds.From, expectedBuilds.To, d)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, g)
+
+			// The groups of the builds stats are the same, in the location of the
+			// times of the records.
+			b, err := stats.ComputeBuildsContext(
+				t.Context(), csv.NewReader(strings.NewReader(strings.Join(groupRecords, "\n"))),
+				expectedBuilds.From, expectedBuilds.To,
+				stats.Options{GroupBy: tc.dim, Location: time.FixedZone("EDT", -4*60*60)},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, b.Groups)
+		})
+	}
+
+	t.Run("successful: builds groups resumed from the checkpoints", func(t *testing.T) {
+		var (
+			in          = strings.Join(groupRecords, "\n")
+			checkpoints []stats.Checkpoint
+			opts        = stats.Options{
+				GroupBy:            "user",
+				CheckpointInterval: 1,
+				Checkpoint: func(cp *stats.Checkpoint) error {
+					var data, err = cp.State.MarshalJSON()
+					require.NoError(t, err)
+
+					var state stats.Aggregator
+					require.NoError(t, state.UnmarshalJSON(data))
+					checkpoints = append(checkpoints, stats.Checkpoint{Offset: cp.Offset, Line: cp.Line, State: &state})
+					return nil
+				},
+			}
+		)
+
+		var expected, err = stats.ComputeBuildsContext(
+			t.Context(), csv.NewReader(strings.NewReader(in)), expectedBuilds.From, expectedBuilds.To, opts,
+		)
+		require.NoError(t, err)
+		require.Len(t, expected.Groups, 3)
+		require.Len(t, checkpoints, len(groupRecords))
+
+		for i := range checkpoints {
+			var cp = checkpoints[i]
+			var b, err = stats.ComputeBuildsContext(
+				t.Context(), csv.NewReader(strings.NewReader(in[cp.Offset:])), expectedBuilds.From, expectedBuilds.To,
+				stats.Options{GroupBy: "user", Resume: &cp},
+			)
+			require.NoError(t, err, "checkpoint %d", i)
+			assert.Equal(t, expected, b, "checkpoint %d", i)
+		}
+
+		_, err = stats.ComputeBuildsContext(
+			t.Context(), csv.NewReader(strings.NewReader(in[checkpoints[0].Offset:])),
+			expectedBuilds.From, expectedBuilds.To,
+			stats.Options{GroupBy: "exit-code", Resume: &checkpoints[0]},
+		)
+		assert.Error(t, err, "the checkpoint is of other groups")
+	})
+
+	t.Run("successful: derived dimension", func(t *testing.T) {
+		var queued = func(rec *stats.Record) (string, int) {
+			if rec.ExecStart.Sub(rec.ReqTime) > 0 {
+				return "queued", 1
+			}
+			return "immediate", 0
+		}
+
+		var in = strings.NewReader(strings.Join(groupRecords, "\n"))
+		var g, err = stats.ComputeGroups(csv.NewReader(in), expectedBuilds.From, expectedBuilds.To, queued)
+		require.NoError(t, err)
+		if assert.Len(t, g, 2) {
+			assert.Equal(t, "immediate", g[0].Key)
+			assert.Equal(t, uint64(3), g[0].Counts.Num)
+			assert.Equal(t, "queued", g[1].Key)
+			assert.Equal(t, 20*time.Minute, g[1].Durations.Mean())
+		}
+	})
+
+	t.Run("successful: dimension in location", func(t *testing.T) {
+		var loc, err = time.LoadLocation("Asia/Tokyo")
+		require.NoError(t, err)
+
+		var in = strings.NewReader(strings.Join(groupRecords, "\n"))
+		g, err := stats.ComputeGroups(
+			csv.NewReader(in), expectedBuilds.From, expectedBuilds.To, stats.InLocation(stats.ByWeekday, loc),
+		)
+		require.NoError(t, err)
+		if assert.Len(t, g, 2) {
+			assert.Equal(t, "Wednesday", g[0].Key)
+			assert.Equal(t, uint64(2), g[0].Counts.Num)
+			assert.Equal(t, "Thursday", g[1].Key)
+			assert.Equal(t, uint64(3), g[1].Counts.Num)
+		}
+	})
+
+	t.Run("error: invalid dimension name", func(t *testing.T) {
+		var _, err = stats.DimensionByName("month")
+		assert.Error(t, err)
+	})
+
+	t.Run("error: invalid builds groups name", func(t *testing.T) {
+		var in = strings.NewReader(strings.Join(groupRecords, "\n"))
+		var _, err = stats.ComputeBuildsContext(
+			t.Context(), csv.NewReader(in), expectedBuilds.From, expectedBuilds.To, stats.Options{GroupBy: "month"},
+		)
+		assert.Error(t, err)
+	})
+
+	t.Run("error: nil dimension", func(t *testing.T) {
+		var in = strings.NewReader(strings.Join(groupRecords, "\n"))
+		var _, err = stats.ComputeGroups(csv.NewReader(in), expectedBuilds.From, expectedBuilds.To, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("error: invalid record", func(t *testing.T) {
+		var in = strings.NewReader(strings.Join(recordsUserA, "\n"))
+		var _, err = stats.ComputeGroups(csv.NewReader(in), expectedBuilds.From, expectedBuilds.To, stats.ByUser)
+		assert.Equal(t, stats.ErrInvalidRecord, err)
+	})
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512B", stats.FormatSize(512))
+	assert.Equal(t, "1.5KB", stats.FormatSize(1500))
+	assert.Equal(t, "100MB", stats.FormatSize(100e6))
+	assert.Equal(t, "2TB", stats.FormatSize(2e12))
+	assert.Equal(t, "1.23MB", stats.FormatSize(1234567))
+}
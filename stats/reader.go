@@ -3,12 +3,63 @@ package stats
 import (
 	"encoding/csv"
 	"errors"
+	"fmt"
 	"time"
 )
 
 // ErrInvalidTime can be returned in csv.ParseError.Err
 var ErrInvalidTime = errors.New("Invalid format time")
 
+// Bounds indicates which limits of a time window are inside of it.
+type Bounds uint8
+
+// The bounds of a time window.
+const (
+	// BoundsClosed includes both limits, [from, to]; it's the default.
+	BoundsClosed Bounds = iota
+	// BoundsHalfOpen includes from but not to, [from, to), so consecutive time
+	// windows, where each one starts at the end of the previous one, don't
+	// share any record.
+	BoundsHalfOpen
+)
+
+// String returns the name of b, which ParseBounds accepts.
+func (b Bounds) String() string {
+	switch b {
+	case BoundsClosed:
+		return "closed"
+	case BoundsHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseBounds returns the Bounds whose String method returns name.
+func ParseBounds(name string) (Bounds, error) {
+	for _, b := range []Bounds{BoundsClosed, BoundsHalfOpen} {
+		if b.String() == name {
+			return b, nil
+		}
+	}
+
+	return BoundsClosed, fmt.Errorf("Invalid bounds %q. Valid ones are: closed, half-open", name)
+}
+
+// Contains reports if t is inside of the time window between from and to with
+// b.
+func (b Bounds) Contains(from time.Time, to time.Time, t time.Time) bool {
+	if t.Before(from) {
+		return false
+	}
+
+	if b == BoundsHalfOpen {
+		return t.Before(to)
+	}
+
+	return !t.After(to)
+}
+
 // Reader is the interface with only the methods of csv.Reader which are used
 // by this package
 type Reader interface {
@@ -19,8 +70,12 @@ type timeWindowReader struct {
 	r      *csv.Reader
 	from   time.Time
 	to     time.Time
+	bounds Bounds
 	tField uint32
 	rowIdx uint64
+	// visit is called, when it isn't nil, after reading each record, indicating
+	// if it's inside of the time window; Read returns the error that it returns.
+	visit func(matched bool) error
 }
 
 // NewTimeWindowReader returns a Reader whose Read method only returns the
@@ -29,6 +84,12 @@ type timeWindowReader struct {
 // is the one to calculate if it's in the specified time window.
 // An error is returned if r is nil or to is previous to from.
 func NewTimeWindowReader(r *csv.Reader, from time.Time, to time.Time) (Reader, error) {
+	return NewTimeWindowReaderBounds(r, from, to, BoundsClosed)
+}
+
+// NewTimeWindowReaderBounds is like NewTimeWindowReader but the limits of the
+// time window which are included are indicated by b.
+func NewTimeWindowReaderBounds(r *csv.Reader, from time.Time, to time.Time, b Bounds) (Reader, error) {
 	if r == nil {
 		return nil, errors.New("Invalid argument. Reader cannot be nil")
 	}
@@ -41,6 +102,7 @@ func NewTimeWindowReader(r *csv.Reader, from time.Time, to time.Time) (Reader, e
 		r:      r,
 		from:   from.Round(0), // strip monotonic clock
 		to:     to.Round(0),   // strip monotonic clock
+		bounds: b,
 		tField: 4,
 	}, nil
 }
@@ -50,7 +112,7 @@ func NewTimeWindowReader(r *csv.Reader, from time.Time, to time.Time) (Reader, e
 // data.
 // It behaves as csv.Reader.Read but also it returns ErrInvalidTime error
 // if the field which  must contain the time under filtering isn't of the
-// expected format.
+// expected format and csv.ErrFieldCount if the record doesn't have it.
 func (twr *timeWindowReader) Read() ([]string, error) {
 	for {
 		var rc, err = twr.r.Read()
@@ -58,11 +120,24 @@ func (twr *timeWindowReader) Read() ([]string, error) {
 			return nil, err
 		}
 
+		// The line is the one of the input of the reader, like the ones of its
+		// errors, which isn't the record number when the computation is resumed
+		// from a checkpoint or a record spans several lines.
+		var line, _ = twr.r.FieldPos(0)
+		if len(rc) <= int(twr.tField) {
+			_, _ = twr.r.ReadAll()
+			return nil, &csv.ParseError{
+				Line:   line,
+				Column: len(rc),
+				Err:    csv.ErrFieldCount,
+			}
+		}
+
 		tm, err := time.Parse(time.RFC3339, rc[twr.tField])
 		if err != nil {
 			_, _ = twr.r.ReadAll()
 			return nil, &csv.ParseError{
-				Line:   int(twr.rowIdx) + 1,
+				Line:   line,
 				Column: int(twr.tField),
 				Err:    ErrInvalidTime,
 			}
@@ -70,7 +145,14 @@ func (twr *timeWindowReader) Read() ([]string, error) {
 
 		twr.rowIdx++
 
-		if tm.Before(twr.from) || tm.After(twr.to) {
+		var matched = twr.bounds.Contains(twr.from, twr.to, tm)
+		if twr.visit != nil {
+			if err := twr.visit(matched); err != nil {
+				return nil, err
+			}
+		}
+
+		if !matched {
 			continue
 		}
 
@@ -102,6 +102,41 @@ func TestTimeWindowReader_Read(t *testing.T) {
 		assert.Equal(t, io.EOF, err)
 	})
 
+	t.Run("successful: bounds", func(t *testing.T) {
+		var records = []string{
+			"0,1,2,3,2018-10-31T00:00:00-04:00,5",
+			"0,1,2,3,2018-10-31T12:00:00-04:00,5",
+			"0,1,2,3,2018-11-01T00:00:00-04:00,5",
+		}
+
+		var from, err = time.Parse(time.RFC3339, "2018-10-31T00:00:00-04:00")
+		require.NoError(t, err)
+		to, err := time.Parse(time.RFC3339, "2018-11-01T00:00:00-04:00")
+		require.NoError(t, err)
+
+		var tcases = []struct {
+			bounds   stats.Bounds
+			expected []string
+		}{
+			{bounds: stats.BoundsClosed, expected: records},
+			{bounds: stats.BoundsHalfOpen, expected: records[:2]},
+		}
+
+		for _, tc := range tcases {
+			var in = strings.Join(records, "\n")
+			twr, err := stats.NewTimeWindowReaderBounds(csv.NewReader(strings.NewReader(in)), from, to, tc.bounds)
+			require.NoError(t, err)
+
+			var read []string
+			for record, err := twr.Read(); err != io.EOF; record, err = twr.Read() {
+				require.NoError(t, err)
+				read = append(read, strings.Join(record, ","))
+			}
+
+			assert.Equal(t, tc.expected, read, tc.bounds.String())
+		}
+	})
+
 	t.Run("error: csv.Reader.Read", func(t *testing.T) {
 		var records = []string{
 			"0,1,2,3,2018-10-31T02:47:31-04:00,5",
@@ -128,6 +163,34 @@ func TestTimeWindowReader_Read(t *testing.T) {
 		assert.Equal(t, io.EOF, err)
 	})
 
+	t.Run("error: records without the time field", func(t *testing.T) {
+		var in = "0,1,2\n0,1,2"
+		var twr, err = stats.NewTimeWindowReader(
+			csv.NewReader(strings.NewReader(in)), time.Time{}, time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC),
+		)
+		require.NoError(t, err)
+
+		_, err = twr.Read()
+		assert.Equal(t, &csv.ParseError{Line: 1, Column: 3, Err: csv.ErrFieldCount}, err)
+
+		_, err = twr.Read()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("error: records without the time field after a multiline record", func(t *testing.T) {
+		var in = "0,1,2,3,2018-10-30T02:47:31-04:00,\"5\n5\"\n0,1,2"
+		var r = csv.NewReader(strings.NewReader(in))
+		r.FieldsPerRecord = -1
+		var twr, err = stats.NewTimeWindowReader(r, time.Time{}, time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		_, err = twr.Read()
+		assert.NoError(t, err)
+
+		_, err = twr.Read()
+		assert.Equal(t, &csv.ParseError{Line: 3, Column: 3, Err: csv.ErrFieldCount}, err)
+	})
+
 	t.Run("error: invalid format time field", func(t *testing.T) {
 		var records = []string{
 			"0,1,2,3,2018-10-30T02:47:31-04:00,5",
@@ -0,0 +1,95 @@
+package stats
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"time"
+)
+
+// Records is a set of records kept in memory, sorted by their execution finish
+// time, for computing the stats of any time window without reading the CSV
+// again.
+type Records struct {
+	recs []*Record
+}
+
+// LoadRecords reads all the pending records of r, which must have all their
+// fields of the expected format, see NewFullRecordFromCSV.
+func LoadRecords(r *csv.Reader) (*Records, error) {
+	var recs []*Record
+	for {
+		var csvr, err = r.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		rec, err := NewFullRecordFromCSV(csvr)
+		if err != nil {
+			return nil, err
+		}
+
+		recs = append(recs, rec)
+	}
+
+	sort.SliceStable(recs, func(i, j int) bool {
+		return recs[i].ExecEnd.Before(recs[j].ExecEnd)
+	})
+
+	return &Records{recs: recs}, nil
+}
+
+// Len returns the number of records.
+func (rs *Records) Len() int {
+	return len(rs.recs)
+}
+
+// window returns the records whose execution finished in the time window
+// between from and to with b.
+func (rs *Records) window(from time.Time, to time.Time, b Bounds) []*Record {
+	var (
+		i = sort.Search(len(rs.recs), func(i int) bool { return !rs.recs[i].ExecEnd.Before(from) })
+		j = sort.Search(len(rs.recs), func(i int) bool {
+			if b == BoundsHalfOpen {
+				return !rs.recs[i].ExecEnd.Before(to)
+			}
+
+			return rs.recs[i].ExecEnd.After(to)
+		})
+	)
+
+	if i >= j {
+		return nil
+	}
+
+	return rs.recs[i:j]
+}
+
+// Builds returns the stats of the records of the time window between from and
+// to with b, whose timeline is of g in loc, see Aggregator.EnableTimeline.
+func (rs *Records) Builds(from time.Time, to time.Time, b Bounds, g Granularity, loc *time.Location) *Builds {
+	var agg = NewAggregator(from, to)
+	agg.EnableTimeline(g, loc)
+	agg.SetBounds(b)
+
+	for _, rec := range rs.window(from, to, b) {
+		agg.Add(rec)
+	}
+
+	return agg.Builds()
+}
+
+// Groups returns the stats of each group of d of the records of the time window
+// between from and to with b, sorted like ComputeGroups does.
+func (rs *Records) Groups(from time.Time, to time.Time, b Bounds, d Dimension) []Group {
+	var gs = newGroupSet(d)
+	for _, rec := range rs.window(from, to, b) {
+		gs.add(rec)
+	}
+
+	return gs.sorted()
+}
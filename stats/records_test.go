@@ -0,0 +1,67 @@
+package stats_test
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecords(t *testing.T) {
+	// The records aren't sorted by their execution finish time.
+	var in = strings.Join([]string{groupRecords[4], groupRecords[2], groupRecords[0], groupRecords[3], groupRecords[1]}, "\n")
+
+	var rs, err = stats.LoadRecords(csv.NewReader(strings.NewReader(in)))
+	require.NoError(t, err)
+	assert.Equal(t, 5, rs.Len())
+
+	var windows = []struct {
+		desc   string
+		from   string
+		to     string
+		bounds stats.Bounds
+	}{
+		{desc: "all", from: "2018-10-31T00:00:00-04:00", to: "2018-11-02T00:00:00-04:00"},
+		{desc: "limits are included", from: "2018-10-31T10:31:00-04:00", to: "2018-11-01T09:05:00-04:00"},
+		{
+			desc: "end is excluded", from: "2018-10-31T10:31:00-04:00", to: "2018-11-01T09:05:00-04:00",
+			bounds: stats.BoundsHalfOpen,
+		},
+		{desc: "empty", from: "2018-10-31T12:00:00-04:00", to: "2018-11-01T08:00:00-04:00"},
+		{desc: "before all", from: "2018-10-30T00:00:00-04:00", to: "2018-10-30T23:00:00-04:00"},
+	}
+
+	for i := range windows {
+		var w = windows[i]
+		t.Run(w.desc, func(t *testing.T) {
+			t.Parallel()
+
+			from, err := time.Parse(time.RFC3339, w.from)
+			require.NoError(t, err)
+			to, err := time.Parse(time.RFC3339, w.to)
+			require.NoError(t, err)
+
+			expected, err := stats.ComputeBuildsContext(
+				t.Context(), csv.NewReader(strings.NewReader(in)), from, to,
+				stats.Options{Timeline: stats.GranularityHour, Bounds: w.bounds},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, expected, rs.Builds(from, to, w.bounds, stats.GranularityHour, nil))
+
+			expectedGroups, err := stats.ComputeGroupsWith(
+				csv.NewReader(strings.NewReader(in)), from, to, stats.ByUser, stats.GroupOptions{Bounds: w.bounds},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, expectedGroups, rs.Groups(from, to, w.bounds, stats.ByUser))
+		})
+	}
+
+	t.Run("error: invalid record", func(t *testing.T) {
+		var _, err = stats.LoadRecords(csv.NewReader(strings.NewReader(genRecord(time.Now(), "userA", 0))))
+		assert.Equal(t, stats.ErrInvalidRecord, err)
+	})
+}
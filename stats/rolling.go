@@ -0,0 +1,86 @@
+package stats
+
+import (
+	"container/heap"
+	"time"
+)
+
+// RollingWindow keeps in memory the records whose execution finished in the
+// last period of time, for computing the Builds stats of a time window which
+// moves forward as the time passes, for example, while a CSV file grows.
+type RollingWindow struct {
+	length time.Duration
+	agg    *Aggregator
+	recs   recordsHeap
+	// start is the start of the last computed time window, or of the one which
+	// ends at the time of the creation until the first one is computed; older
+	// records are discarded.
+	start time.Time
+}
+
+// NewRollingWindow returns an empty RollingWindow whose time window lasts
+// length and ends at now, so the records which finished before it aren't kept.
+// Its Builds have a timeline of g in loc, see Aggregator.EnableTimeline.
+func NewRollingWindow(length time.Duration, now time.Time, g Granularity, loc *time.Location) *RollingWindow {
+	var agg = NewAggregator(time.Time{}, time.Time{})
+	agg.EnableTimeline(g, loc)
+
+	return &RollingWindow{length: length, agg: agg, start: now.Add(-length)}
+}
+
+// Add accumulates rec unless its execution finished before the start of the
+// last time window computed by Builds, or of the initial one. The records don't
+// need to be added in any specific order.
+func (rw *RollingWindow) Add(rec *Record) {
+	if rec.ExecEnd.Before(rw.start) {
+		return
+	}
+
+	heap.Push(&rw.recs, rec)
+	rw.agg.Add(rec)
+}
+
+// Builds returns the stats of the records of the time window which ends at
+// now, discarding the records which finished before it starts. The records
+// which finish after now are included, because they are usually due to
+// slightly unsynchronized clocks. now cannot be previous to the one of the
+// previous call.
+func (rw *RollingWindow) Builds(now time.Time) *Builds {
+	rw.start = now.Add(-rw.length)
+	for len(rw.recs) > 0 && rw.recs[0].ExecEnd.Before(rw.start) {
+		rw.agg.Remove(heap.Pop(&rw.recs).(*Record))
+	}
+
+	var b = rw.agg.Builds()
+	b.From = rw.start
+	b.To = now
+	return b
+}
+
+// Len returns the number of records kept in memory.
+func (rw *RollingWindow) Len() int {
+	return len(rw.recs)
+}
+
+// recordsHeap is a min-heap of records by their execution finish time.
+type recordsHeap []*Record
+
+func (h recordsHeap) Len() int           { return len(h) }
+func (h recordsHeap) Less(i, j int) bool { return h[i].ExecEnd.Before(h[j].ExecEnd) }
+func (h recordsHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *recordsHeap) Push(x interface{}) {
+	*h = append(*h, x.(*Record))
+}
+
+func (h *recordsHeap) Pop() interface{} {
+	var (
+		old = *h
+		n   = len(old)
+		rec = old[n-1]
+	)
+
+	old[n-1] = nil
+	*h = old[:n-1]
+	return rec
+}
@@ -0,0 +1,85 @@
+package stats_test
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollingWindow(t *testing.T) {
+	var records []string
+	for _, rs := range [][]string{recordsUserA, recordsUserB, recordsUserC, recordsUserD, recordsUserE} {
+		records = append(records, rs...)
+	}
+
+	var (
+		in     = strings.Join(records, "\n")
+		length = 6 * time.Hour
+		rw     = stats.NewRollingWindow(length, expectedBuilds.From, stats.GranularityHour, time.UTC)
+	)
+
+	var r = csv.NewReader(strings.NewReader(in))
+	for {
+		var csvr, err = r.Read()
+		if err != nil {
+			break
+		}
+
+		rec, err := stats.NewRecordFromCSV(csvr)
+		require.NoError(t, err)
+		rw.Add(rec)
+	}
+
+	for now := expectedBuilds.From; !now.After(expectedBuilds.To.Add(length)); now = now.Add(90 * time.Minute) {
+		// The window moves forward in the time, but only the records which are
+		// older than it are discarded.
+		var from = now.Add(-length)
+		expected, err := stats.ComputeBuildsContext(
+			t.Context(), csv.NewReader(strings.NewReader(in)), from, expectedBuilds.To.Add(length),
+			stats.Options{Timeline: stats.GranularityHour, Location: time.UTC},
+		)
+		require.NoError(t, err)
+		expected.To = now
+
+		var b = rw.Builds(now)
+		assert.Equal(t, expected, b, "now: %s", now)
+		assert.Equal(t, int(b.Num), rw.Len())
+	}
+
+	t.Run("records older than the initial window aren't kept", func(t *testing.T) {
+		// The window is created while the CSV is growing, when its first records
+		// are old, so the backlog of the file isn't kept in memory.
+		var (
+			now = expectedBuilds.From.Add(expectedBuilds.To.Sub(expectedBuilds.From) / 2)
+			rw  = stats.NewRollingWindow(length, now, stats.GranularityHour, time.UTC)
+			r   = csv.NewReader(strings.NewReader(in))
+		)
+
+		for csvr, err := r.Read(); err == nil; csvr, err = r.Read() {
+			var rec, err = stats.NewRecordFromCSV(csvr)
+			require.NoError(t, err)
+			rw.Add(rec)
+		}
+
+		var kept = rw.Len()
+		var b = rw.Builds(now)
+		assert.Equal(t, int(b.Num), kept)
+		assert.True(t, b.Num > 0 && b.Num < expectedBuilds.Num)
+	})
+
+	t.Run("records older than the window are ignored", func(t *testing.T) {
+		var rec, err = stats.NewRecordFromCSV(strings.Split(genRecord(expectedBuilds.From, "userZ", 1), ","))
+		require.NoError(t, err)
+
+		var before = rw.Len()
+		rw.Add(rec)
+		assert.Equal(t, before, rw.Len())
+		assert.True(t, rw.Builds(expectedBuilds.To.Add(2*length)).Empty())
+		assert.Equal(t, 0, rw.Len())
+	})
+}
@@ -0,0 +1,248 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Action is what is done with a record which violates a rule.
+type Action uint8
+
+// The actions for the records which violate a rule.
+const (
+	// ActionIgnore doesn't check the rule.
+	ActionIgnore Action = iota
+	// ActionWarn reports the violation, but the record is used.
+	ActionWarn
+	// ActionReject reports the violation and the record isn't used.
+	ActionReject
+)
+
+// String returns the name of the action, which ParseAction accepts.
+func (a Action) String() string {
+	switch a {
+	case ActionIgnore:
+		return "ignore"
+	case ActionWarn:
+		return "warn"
+	case ActionReject:
+		return "reject"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseAction returns the action whose String method returns name.
+func ParseAction(name string) (Action, error) {
+	for a := ActionIgnore; a <= ActionReject; a++ {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+
+	return ActionIgnore, fmt.Errorf("Invalid action %q. Valid ones are: ignore, warn, reject", name)
+}
+
+// SemanticRules are the rules about the consistency of the values of the fields
+// of a record, which the computations can check with an action chosen for each
+// one, see SemanticOptions.
+var SemanticRules = []Rule{
+	RuleStartBeforeRequest, RuleEndBeforeStart, RuleFutureTime, RuleNegativeSize, RuleDeletedVocabulary,
+}
+
+// DeletedVocabulary contains the values of the deleted flag of the exports;
+// the rest of the values violate RuleDeletedVocabulary.
+var DeletedVocabulary = []string{"true", "false"}
+
+// SemanticOptions configures the checks of the SemanticRules. The computations
+// which check them also parse the fields of the records which the checked rules
+// need, and don't use the rejected ones.
+type SemanticOptions struct {
+	// Actions is what is done with the records which violate each rule; the
+	// rules which aren't in it are ignored.
+	Actions map[Rule]Action
+	// Now is the time after which the times of a record are in the future; it's
+	// the current time when the check starts if it's zero.
+	Now time.Time
+	// Report is called, when it isn't nil, with each violation of a rule whose
+	// action isn't ActionIgnore.
+	Report func(Violation)
+}
+
+// Enabled reports if any rule is checked.
+func (so SemanticOptions) Enabled() bool {
+	for _, a := range so.Actions {
+		if a != ActionIgnore {
+			return true
+		}
+	}
+
+	return false
+}
+
+// now returns Now or the current time if it's zero.
+func (so SemanticOptions) now() time.Time {
+	if so.Now.IsZero() {
+		return time.Now()
+	}
+
+	return so.Now
+}
+
+// checked reports if any of rules isn't ignored.
+func (so SemanticOptions) checked(rules ...Rule) bool {
+	for _, r := range rules {
+		if so.Actions[r] != ActionIgnore {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseFields sets the fields of rec which the rules that aren't ignored check,
+// except the deleted flag, whose raw value is checked, parsing them from csvr.
+// It returns ErrInvalidRecord if any of them fails to parse.
+func (so SemanticOptions) parseFields(rec *Record, csvr []string) error {
+	var err error
+	if so.checked(RuleStartBeforeRequest, RuleFutureTime) {
+		if rec.ReqTime, err = time.Parse(time.RFC3339, csvr[2]); err != nil {
+			return ErrInvalidRecord
+		}
+	}
+
+	if so.checked(RuleStartBeforeRequest, RuleEndBeforeStart, RuleFutureTime) {
+		if rec.ExecStart, err = time.Parse(time.RFC3339, csvr[3]); err != nil {
+			return ErrInvalidRecord
+		}
+	}
+
+	if so.checked(RuleNegativeSize) {
+		if len(csvr) < 8 {
+			return ErrInvalidRecord
+		}
+
+		if rec.ImageSize, err = strconv.ParseInt(csvr[7], 10, 64); err != nil {
+			return ErrInvalidRecord
+		}
+	}
+
+	return nil
+}
+
+// check returns the violations of the rules which aren't ignored by rec, which
+// starts on line, and whose raw deleted flag is deleted; the times of rec which
+// are zero aren't checked.
+func (so SemanticOptions) check(line int, rec *Record, deleted string, now time.Time) []Violation {
+	var violations []Violation
+	var violate = func(r Rule, format string, args ...interface{}) {
+		if a := so.Actions[r]; a != ActionIgnore {
+			violations = append(violations, Violation{
+				Line: line, Rule: r, Action: a, Message: fmt.Sprintf(format, args...),
+			})
+		}
+	}
+
+	var known = func(ts ...time.Time) bool {
+		for _, t := range ts {
+			if t.IsZero() {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if known(rec.ReqTime, rec.ExecStart) && rec.ExecStart.Before(rec.ReqTime) {
+		violate(RuleStartBeforeRequest, "Execution start time %s is before the request time %s",
+			rec.ExecStart.Format(time.RFC3339), rec.ReqTime.Format(time.RFC3339))
+	}
+
+	if known(rec.ExecStart, rec.ExecEnd) && rec.ExecEnd.Before(rec.ExecStart) {
+		violate(RuleEndBeforeStart, "Execution end time %s is before the execution start time %s",
+			rec.ExecEnd.Format(time.RFC3339), rec.ExecStart.Format(time.RFC3339))
+	}
+
+	var names = [3]string{"request", "execution start", "execution end"}
+	for i, t := range [3]time.Time{rec.ReqTime, rec.ExecStart, rec.ExecEnd} {
+		if t.After(now) {
+			violate(RuleFutureTime, "The %s time %s is in the future", names[i], t.Format(time.RFC3339))
+		}
+	}
+
+	if rec.ImageSize < 0 {
+		violate(RuleNegativeSize, "Image size %d is negative", rec.ImageSize)
+	}
+
+	var inVocabulary bool
+	for _, v := range DeletedVocabulary {
+		inVocabulary = inVocabulary || v == deleted
+	}
+
+	if !inVocabulary {
+		violate(RuleDeletedVocabulary, "Deleted flag %q isn't any of %v", deleted, DeletedVocabulary)
+	}
+
+	return violations
+}
+
+// semanticFilter applies SemanticOptions to the records read by a computation.
+type semanticFilter struct {
+	opts SemanticOptions
+	now  time.Time
+	r    *csv.Reader
+	// violations is the number of violations of each rule and rejected the
+	// number of records with at least one violation of a rule whose action is
+	// ActionReject.
+	violations map[Rule]uint64
+	rejected   uint64
+}
+
+// newSemanticFilter returns a semanticFilter of the records of r, or nil if
+// opts doesn't check any rule.
+func newSemanticFilter(r *csv.Reader, opts SemanticOptions) *semanticFilter {
+	if !opts.Enabled() {
+		return nil
+	}
+
+	return &semanticFilter{opts: opts, now: opts.now(), r: r, violations: map[Rule]uint64{}}
+}
+
+// parse returns the record of csvr, which is the last record read from the
+// reader of sf, parsed with parseFn, and if it must be used. If sf is nil, it's
+// always used, otherwise it also has the fields which the checked rules need.
+func (sf *semanticFilter) parse(csvr []string, parseFn func([]string) (*Record, error)) (*Record, bool, error) {
+	var rec, err = parseFn(csvr)
+	if sf == nil || err != nil {
+		return rec, err == nil, err
+	}
+
+	if err = sf.opts.parseFields(rec, csvr); err != nil {
+		return nil, false, err
+	}
+
+	var (
+		line, _    = sf.r.FieldPos(0)
+		violations = sf.opts.check(line, rec, csvr[5], sf.now)
+		accept     = true
+	)
+
+	for _, v := range violations {
+		sf.violations[v.Rule]++
+		if v.Action == ActionReject {
+			accept = false
+		}
+
+		if sf.opts.Report != nil {
+			sf.opts.Report(v)
+		}
+	}
+
+	if !accept {
+		sf.rejected++
+	}
+
+	return rec, accept, nil
+}
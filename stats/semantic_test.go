@@ -0,0 +1,203 @@
+package stats_test
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAction(t *testing.T) {
+	for _, a := range []stats.Action{stats.ActionIgnore, stats.ActionWarn, stats.ActionReject} {
+		var parsed, err = stats.ParseAction(a.String())
+		require.NoError(t, err)
+		assert.Equal(t, a, parsed)
+	}
+
+	var _, err = stats.ParseAction("random")
+	assert.Error(t, err)
+}
+
+func TestSemantics(t *testing.T) {
+	var (
+		from = time.Date(2018, 10, 31, 0, 0, 0, 0, time.UTC)
+		to   = time.Date(2018, 11, 2, 0, 0, 0, 0, time.UTC)
+		// b6 finishes before starting, because of the clock of its node, and b7
+		// has a negative image size.
+		in = strings.Join(append(groupRecords,
+			"b6,userC,2018-11-01T09:00:00-04:00,2018-11-01T09:00:00-04:00,2018-11-01T08:00:00-04:00,false,1,1",
+			"b7,userC,2018-11-01T09:00:00-04:00,2018-11-01T09:00:00-04:00,2018-11-01T09:10:00-04:00,false,1,-1",
+		), "\n")
+	)
+
+	var tcases = []struct {
+		desc       string
+		actions    map[stats.Rule]stats.Action
+		num        uint64
+		rejected   uint64
+		violations map[stats.Rule]uint64
+		reported   int
+	}{
+		{
+			desc: "ignore",
+			num:  7,
+		},
+		{
+			desc: "warn",
+			actions: map[stats.Rule]stats.Action{
+				stats.RuleEndBeforeStart: stats.ActionWarn,
+				stats.RuleNegativeSize:   stats.ActionWarn,
+			},
+			num:        7,
+			violations: map[stats.Rule]uint64{stats.RuleEndBeforeStart: 1, stats.RuleNegativeSize: 1},
+			reported:   2,
+		},
+		{
+			desc: "reject",
+			actions: map[stats.Rule]stats.Action{
+				stats.RuleEndBeforeStart: stats.ActionReject,
+				stats.RuleNegativeSize:   stats.ActionWarn,
+				stats.RuleFutureTime:     stats.ActionReject,
+			},
+			num:        6,
+			rejected:   1,
+			violations: map[stats.Rule]uint64{stats.RuleEndBeforeStart: 1, stats.RuleNegativeSize: 1},
+			reported:   2,
+		},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var reported int
+			var so = stats.SemanticOptions{
+				Actions: tc.actions,
+				Report: func(v stats.Violation) {
+					assert.Equal(t, tc.actions[v.Rule], v.Action)
+					reported++
+				},
+			}
+
+			var b, err = stats.ComputeBuildsContext(
+				t.Context(), csv.NewReader(strings.NewReader(in)), from, to, stats.Options{Semantics: so},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, tc.num, b.Num)
+			assert.Equal(t, tc.rejected, b.NumRejected)
+			assert.Equal(t, tc.violations, b.Violations)
+			assert.Equal(t, tc.reported, reported)
+
+			so.Report = nil
+			g, err := stats.ComputeGroupsWith(
+				csv.NewReader(strings.NewReader(in)), from, to, stats.ByUser, stats.GroupOptions{Semantics: so},
+			)
+			require.NoError(t, err)
+
+			var grouped uint64
+			for _, g := range g {
+				grouped += g.Counts.Num
+			}
+			assert.Equal(t, tc.num, grouped)
+
+			f, err := stats.FindFlakyUsers(
+				csv.NewReader(strings.NewReader(in)), from, to,
+				stats.FlakyOptions{MinFailureStreak: 3, Semantics: so},
+			)
+			require.NoError(t, err)
+			// userC has 3 consecutive failed builds unless b6 is rejected.
+			assert.Equal(t, tc.rejected == 0, len(f) == 1)
+		})
+	}
+}
+
+func TestSemantics_rawFields(t *testing.T) {
+	var (
+		from = time.Date(2018, 10, 31, 0, 0, 0, 0, time.UTC)
+		to   = time.Date(2018, 11, 2, 0, 0, 0, 0, time.UTC)
+	)
+
+	var tcases = []struct {
+		desc       string
+		rec        string
+		actions    map[stats.Rule]stats.Action
+		num        uint64
+		violations map[stats.Rule]uint64
+		lines      []int
+	}{
+		{
+			desc:       "deleted flag which isn't a boolean",
+			rec:        "b6,userC,2018-11-01T09:00:00-04:00,2018-11-01T09:00:00-04:00,2018-11-01T09:10:00-04:00,yes,1,1",
+			actions:    map[stats.Rule]stats.Action{stats.RuleDeletedVocabulary: stats.ActionReject},
+			num:        5,
+			violations: map[stats.Rule]uint64{stats.RuleDeletedVocabulary: 1},
+			lines:      []int{6},
+		},
+		{
+			desc:       "empty deleted flag",
+			rec:        "b6,userC,2018-11-01T09:00:00-04:00,2018-11-01T09:00:00-04:00,2018-11-01T09:10:00-04:00,,1,1",
+			actions:    map[stats.Rule]stats.Action{stats.RuleDeletedVocabulary: stats.ActionWarn},
+			num:        6,
+			violations: map[stats.Rule]uint64{stats.RuleDeletedVocabulary: 1},
+			lines:      []int{6},
+		},
+		{
+			desc:       "boolean deleted flag out of the vocabulary",
+			rec:        "b6,userC,2018-11-01T09:00:00-04:00,2018-11-01T09:00:00-04:00,2018-11-01T09:10:00-04:00,1,1,1",
+			actions:    map[stats.Rule]stats.Action{stats.RuleDeletedVocabulary: stats.ActionWarn},
+			num:        6,
+			violations: map[stats.Rule]uint64{stats.RuleDeletedVocabulary: 1},
+			lines:      []int{6},
+		},
+		{
+			desc:       "malformed fields which the checked rules don't need",
+			rec:        "b6,userC,2018-11-01,2018-11-01T09:00:00-04:00,2018-11-01T08:00:00-04:00,yes,1,1KB",
+			actions:    map[stats.Rule]stats.Action{stats.RuleEndBeforeStart: stats.ActionWarn},
+			num:        6,
+			violations: map[stats.Rule]uint64{stats.RuleEndBeforeStart: 1},
+			lines:      []int{6},
+		},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var lines []int
+			var so = stats.SemanticOptions{
+				Actions: tc.actions,
+				Report: func(v stats.Violation) {
+					assert.Equal(t, tc.actions[v.Rule], v.Action)
+					lines = append(lines, v.Line)
+				},
+			}
+
+			var in = strings.Join(append(append([]string(nil), groupRecords...), tc.rec), "\n")
+			var b, err = stats.ComputeBuildsContext(
+				t.Context(), csv.NewReader(strings.NewReader(in)), from, to, stats.Options{Semantics: so},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, tc.num, b.Num)
+			assert.Equal(t, tc.violations, b.Violations)
+			assert.Equal(t, tc.lines, lines)
+		})
+	}
+
+	t.Run("error: malformed field which a checked rule needs", func(t *testing.T) {
+		var in = strings.Join(append(append([]string(nil), groupRecords...),
+			"b6,userC,2018-11-01T09:00:00-04:00,2018-11-01T09:00:00-04:00,2018-11-01T09:10:00-04:00,false,1,1KB",
+		), "\n")
+		var so = stats.SemanticOptions{Actions: map[stats.Rule]stats.Action{stats.RuleNegativeSize: stats.ActionWarn}}
+
+		var _, err = stats.ComputeBuildsContext(
+			t.Context(), csv.NewReader(strings.NewReader(in)), from, to, stats.Options{Semantics: so},
+		)
+		assert.Equal(t, stats.ErrInvalidRecord, err)
+	})
+}
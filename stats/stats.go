@@ -1,6 +1,7 @@
 package stats
 
 import (
+	"context"
 	"encoding/csv"
 	"errors"
 	"io"
@@ -14,18 +15,34 @@ import (
 var ErrInvalidRecord = errors.New("Record has at least one field of an unexpected format")
 
 // Record has the typed fields which a CSV record has.
-// NOTE it only has the fields used by the current computation functions.
+// NOTE NewRecordFromCSV only sets the fields used by ComputeBuilds, the rest
+// are only set by NewFullRecordFromCSV.
 type Record struct {
-	UserID   string
-	ExecEnd  time.Time
-	ExitCode uint8
+	BuildID   string
+	UserID    string
+	ReqTime   time.Time
+	ExecStart time.Time
+	ExecEnd   time.Time
+	Deleted   bool
+	ExitCode  uint8
+	ImageSize int64
+}
+
+// Duration returns the time spent executing the build. It's 0 if the record
+// doesn't have the execution start time.
+func (r Record) Duration() time.Duration {
+	if r.ExecStart.IsZero() {
+		return 0
+	}
+
+	return r.ExecEnd.Sub(r.ExecStart)
 }
 
 // NewRecordFromCSV returns a new Record from a CSV record.
 // It can returns ErrInvalidRecord if the execution date or exit code fails or
 // it doesn't have enough number of fields.
 func NewRecordFromCSV(rec []string) (*Record, error) {
-	if len(rec) < 6 {
+	if len(rec) < 7 {
 		return nil, ErrInvalidRecord
 	}
 
@@ -34,124 +51,443 @@ func NewRecordFromCSV(rec []string) (*Record, error) {
 		return nil, ErrInvalidRecord
 	}
 
-	code, err := strconv.ParseInt(rec[6], 10, 8)
+	code, err := ParseExitCode(rec[6])
 	if err != nil {
 		return nil, ErrInvalidRecord
 	}
 
 	return &Record{
+		BuildID:  rec[0],
 		UserID:   rec[1],
 		ExecEnd:  tm,
-		ExitCode: uint8(code),
+		ExitCode: code,
 	}, nil
 }
 
+// ParseExitCode parses the exit code of a CSV record, which must be an integer
+// between 0 and 255.
+func ParseExitCode(s string) (uint8, error) {
+	var code, err = strconv.ParseUint(s, 10, 8)
+	return uint8(code), err
+}
+
+// NewFullRecordFromCSV returns a new Record, with all its fields set, from a
+// CSV record.
+// It returns ErrInvalidRecord if any of the fields fails to parse or it
+// doesn't have the 8 fields of the remote build service CSV records.
+func NewFullRecordFromCSV(rec []string) (*Record, error) {
+	if len(rec) < 8 {
+		return nil, ErrInvalidRecord
+	}
+
+	var r, err = NewRecordFromCSV(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.ReqTime, err = time.Parse(time.RFC3339, rec[2]); err != nil {
+		return nil, ErrInvalidRecord
+	}
+
+	if r.ExecStart, err = time.Parse(time.RFC3339, rec[3]); err != nil {
+		return nil, ErrInvalidRecord
+	}
+
+	if r.Deleted, err = strconv.ParseBool(rec[5]); err != nil {
+		return nil, ErrInvalidRecord
+	}
+
+	if r.ImageSize, err = strconv.ParseInt(rec[7], 10, 64); err != nil {
+		return nil, ErrInvalidRecord
+	}
+
+	return r, nil
+}
+
 // Builds contains the stats of the remote build service in a time window.
+// RateSuccess is 0 when the time window doesn't have any build; use Empty to
+// distinguish it from a window where all the builds failed.
 type Builds struct {
 	From        time.Time
 	To          time.Time
+	Bounds      Bounds
 	Num         uint64
+	NumFailed   uint64
 	TopUsers    [5]string
 	RateSuccess float32
 	TopErrCodes [5]uint8
+	Users       map[string]Counts
+	ErrCodes    map[uint8]uint64
+	// Timeline is only computed when it's requested, see Options.Timeline.
+	Timeline            []TimelineBucket
+	TimelineGranularity Granularity
+	// Groups are only computed when they are requested, see Options.GroupBy,
+	// and sorted like ComputeGroups does.
+	Groups []Group
+	// NumDuplicates is the number of records which haven't been counted for
+	// having the build ID of another one, see Options.Dedup.
+	NumDuplicates uint64
+	// Violations is the number of violations of each rule and NumRejected the
+	// number of records which haven't been counted for violating a rule whose
+	// action is ActionReject; they are only set when any rule is checked, see
+	// Options.Semantics.
+	Violations  map[Rule]uint64
+	NumRejected uint64
+}
+
+// UserBuilds contains the number of builds of a user.
+type UserBuilds struct {
+	UserID string
+	Counts
+}
+
+// ErrCodeBuilds contains the number of builds which failed with an exit code.
+type ErrCodeBuilds struct {
+	ExitCode uint8
+	Num      uint64
+}
+
+// RankedUsers returns the number of builds of each user sorted from the user
+// with more builds to the one with less. Users with the same number of builds
+// are sorted by ID.
+func (b Builds) RankedUsers() []UserBuilds {
+	var users = make([]UserBuilds, 0, len(b.Users))
+	for u, c := range b.Users {
+		users = append(users, UserBuilds{UserID: u, Counts: c})
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Num != users[j].Num {
+			return users[i].Num > users[j].Num
+		}
+
+		return users[i].UserID < users[j].UserID
+	})
+
+	return users
+}
+
+// RankedErrCodes returns the number of failed builds of each exit code sorted
+// from the code with more builds to the one with less. Exit codes with the same
+// number of builds are sorted by value.
+func (b Builds) RankedErrCodes() []ErrCodeBuilds {
+	var codes = make([]ErrCodeBuilds, 0, len(b.ErrCodes))
+	for c, n := range b.ErrCodes {
+		codes = append(codes, ErrCodeBuilds{ExitCode: c, Num: n})
+	}
+
+	sort.Slice(codes, func(i, j int) bool {
+		if codes[i].Num != codes[j].Num {
+			return codes[i].Num > codes[j].Num
+		}
+
+		return codes[i].ExitCode < codes[j].ExitCode
+	})
+
+	return codes
+}
+
+// Empty reports if the time window doesn't have any build.
+func (b Builds) Empty() bool {
+	return b.Num == 0
+}
+
+// SmallSample reports if the time window has less builds than
+// MinReliableSample, hence its success rate isn't trustworthy.
+func (b Builds) SmallSample() bool {
+	return b.counts().SmallSample()
+}
+
+// RateSuccessInterval returns the Wilson score interval of the success rate
+// with a 95% confidence level.
+func (b Builds) RateSuccessInterval() Interval {
+	return b.counts().RateSuccessInterval()
+}
+
+func (b Builds) counts() Counts {
+	return Counts{Num: b.Num, NumFailed: b.NumFailed}
+}
+
+// DefaultProgressInterval is the number of read records between calls to the
+// Options.Progress function when Options.ProgressInterval is 0.
+const DefaultProgressInterval = 10000
+
+// Progress contains how much of the input has been processed.
+type Progress struct {
+	// Records is the number of read records.
+	Records uint64
+	// Bytes is the number of bytes of the input consumed by the read records.
+	Bytes int64
+	// Matched is the number of read records which are inside of the time window.
+	Matched uint64
+}
+
+// ProgressFunc is called for reporting the progress of a computation.
+type ProgressFunc func(Progress)
+
+// Options contains the optional settings of ComputeBuildsContext.
+type Options struct {
+	// Progress is called every ProgressInterval read records and once more when
+	// the computation ends, unless it fails.
+	Progress         ProgressFunc
+	ProgressInterval uint64
+	// Checkpoint is called, approximately, every CheckpointInterval read records
+	// with the current state of the computation, and once more when the context
+	// is done, so the computation can be resumed from where it stopped; the
+	// computation fails with the error that it returns. The passed checkpoint
+	// shares its state with the computation, so it must be saved or copied
+	// before returning.
+	Checkpoint         func(*Checkpoint) error
+	CheckpointInterval uint64
+	// Timeline is the granularity of the Builds timeline; it isn't computed
+	// with GranularityNone, which is the default.
+	Timeline Granularity
+	// GroupBy is the name of the Dimension of the Builds groups, see
+	// DimensionByName; they aren't computed when it's empty, which is the
+	// default. The records must have all their fields of the expected format,
+	// see NewFullRecordFromCSV.
+	GroupBy string
+	// Location is where the timeline buckets start at its hours, days or weeks,
+	// and the one of the times of the records grouped by GroupBy; it's UTC when
+	// it's nil.
+	Location *time.Location
+	// Resume continues the computation from a checkpoint, so the reader must
+	// start reading the input from its Offset. The checkpoint must be of a
+	// computation of the same time window.
+	Resume *Checkpoint
+	// Dedup counts only one of the records of the time window with the same
+	// build ID, according to its policy; it's disabled with DedupNone, which is
+	// the default. It cannot be used with checkpoints.
+	Dedup DedupOptions
+	// Bounds are the included limits of the time window and Semantics the
+	// rules checked on its records, which cannot be used with checkpoints.
+	Bounds    Bounds
+	Semantics SemanticOptions
 }
 
 // ComputeBuilds calculate the stats of the remote build server of r records
 // pending to read considering the passed time window.
 func ComputeBuilds(r *csv.Reader, from time.Time, to time.Time) (*Builds, error) {
-	var twr, err = NewTimeWindowReader(r, from, to)
+	return ComputeBuildsContext(context.Background(), r, from, to, Options{})
+}
+
+// ComputeBuildsContext is like ComputeBuilds but it stops reading records and
+// returns ctx.Err() as soon as ctx is done, and it accepts opts.
+func ComputeBuildsContext(
+	ctx context.Context, r *csv.Reader, from time.Time, to time.Time, opts Options,
+) (*Builds, error) {
+	var twri, err = NewTimeWindowReaderBounds(r, from, to, opts.Bounds)
 	if err != nil {
 		return nil, err
 	}
 
 	var (
-		csvr          []string
-		nBuilds       uint64
-		nBuildsFailed uint64
-		usersM        = map[string]int{}
-		usersNBuilds  = []struct {
-			u string
-			n uint64
-		}{}
-		errCodesM       = map[uint8]int{}
-		errCodesNBuilds = []struct {
-			c uint8
-			n uint64
-		}{}
+		twr    = twri.(*timeWindowReader)
+		agg    = NewAggregator(from, to)
+		offset int64
 	)
 
+	agg.EnableTimeline(opts.Timeline, opts.Location)
+	agg.SetBounds(opts.Bounds)
+
+	var sf = newSemanticFilter(r, opts.Semantics)
+	if sf != nil && (opts.Checkpoint != nil || opts.Resume != nil) {
+		return nil, errors.New("Invalid argument. Semantics cannot be used with checkpoints")
+	}
+
+	var (
+		dd      *Deduplicator
+		parseFn = NewRecordFromCSV
+	)
+	if opts.Dedup.Policy != DedupNone {
+		if opts.Checkpoint != nil || opts.Resume != nil {
+			return nil, errors.New("Invalid argument. Dedup cannot be used with checkpoints")
+		}
+
+		if dd, err = NewDeduplicator(opts.Dedup); err != nil {
+			return nil, err
+		}
+
+		// The conflicts are detected comparing all the fields.
+		if opts.Dedup.Policy == DedupError {
+			parseFn = NewFullRecordFromCSV
+		}
+	}
+
+	// lastGroups are the groups computed at the end from the records counted
+	// by dd, because they cannot discount the records replaced by the last
+	// ones of their builds.
+	var lastGroups *groupSet
+	if opts.GroupBy != "" {
+		parseFn = NewFullRecordFromCSV
+
+		if opts.Dedup.Policy == DedupLastWins {
+			var d, err = dimensionIn(opts.GroupBy, opts.Location)
+			if err != nil {
+				return nil, err
+			}
+
+			lastGroups = newGroupSet(d)
+		} else if err = agg.EnableGroups(opts.GroupBy, opts.Location); err != nil {
+			return nil, err
+		}
+	}
+
+	if cp := opts.Resume; cp != nil {
+		if !cp.State.from.Equal(from) || !cp.State.to.Equal(to) || cp.State.bounds != opts.Bounds {
+			return nil, errors.New("Invalid argument. Checkpoint is of a different time window")
+		}
+
+		if cp.State.timelineG != opts.Timeline || cp.State.groupBy != opts.GroupBy ||
+			((opts.Timeline != GranularityNone || opts.GroupBy != "") && locationName(cp.State.loc) != agg.loc.String()) {
+			return nil, errors.New("Invalid argument. Checkpoint is of a different timeline granularity, groups or location")
+		}
+
+		agg = cp.State.clone()
+		offset = cp.Offset
+		twr.rowIdx = cp.Line
+	}
+
+	var (
+		pInterval  = opts.ProgressInterval
+		cpInterval = opts.CheckpointInterval
+		cpPending  bool
+		// cancelled indicates that the context is done, but the computation
+		// stops after accumulating the last read record for checkpointing it.
+		cancelled bool
+		progress  = func() {
+			opts.Progress(Progress{
+				Records: twr.rowIdx,
+				Bytes:   offset + r.InputOffset(),
+				Matched: agg.Num(),
+			})
+		}
+		checkpoint = func() error {
+			cpPending = false
+			return opts.Checkpoint(&Checkpoint{
+				Offset: offset + r.InputOffset(),
+				Line:   twr.rowIdx,
+				State:  agg,
+			})
+		}
+	)
+
+	if pInterval == 0 {
+		pInterval = DefaultProgressInterval
+	}
+
+	if cpInterval == 0 {
+		cpInterval = DefaultCheckpointInterval
+	}
+
+	twr.visit = func(matched bool) error {
+		select {
+		case <-ctx.Done():
+			if opts.Checkpoint == nil {
+				return ctx.Err()
+			}
+
+			if matched {
+				cancelled, cpPending = true, true
+				return nil
+			}
+
+			if err := checkpoint(); err != nil {
+				return err
+			}
+
+			return ctx.Err()
+		default:
+		}
+
+		if opts.Progress != nil && twr.rowIdx%pInterval == 0 {
+			progress()
+		}
+
+		if opts.Checkpoint != nil && twr.rowIdx%cpInterval == 0 {
+			// A matched record is accumulated after being returned, so the
+			// checkpoint has to wait until then for being consistent.
+			if matched {
+				cpPending = true
+			} else {
+				return checkpoint()
+			}
+		}
+
+		return nil
+	}
+
+	var csvr []string
 	for csvr, err = twr.Read(); err == nil; csvr, err = twr.Read() {
-		var rec *Record
-		rec, err = NewRecordFromCSV(csvr)
+		var (
+			rec    *Record
+			accept bool
+		)
+
+		rec, accept, err = sf.parse(csvr, parseFn)
 		if err != nil {
 			break
 		}
 
-		nBuilds++
+		if !accept {
+			continue
+		}
+
+		if dd != nil {
+			var add, replaced, derr = dd.Check(rec)
+			if derr != nil {
+				err = derr
+				break
+			}
+
+			if replaced != nil {
+				agg.Remove(replaced)
+			}
 
-		if i, ok := usersM[rec.UserID]; ok {
-			usersNBuilds[i].n++
-		} else {
-			usersM[rec.UserID] = len(usersNBuilds)
-			usersNBuilds = append(usersNBuilds,
-				struct {
-					u string
-					n uint64
-				}{rec.UserID, 1},
-			)
+			if !add {
+				continue
+			}
 		}
 
-		if rec.ExitCode > 0 {
-			nBuildsFailed++
+		agg.Add(rec)
 
-			if i, ok := errCodesM[rec.ExitCode]; ok {
-				errCodesNBuilds[i].n++
-			} else {
-				errCodesM[rec.ExitCode] = len(errCodesNBuilds)
-				errCodesNBuilds = append(errCodesNBuilds,
-					struct {
-						c uint8
-						n uint64
-					}{rec.ExitCode, 1},
-				)
+		if cpPending {
+			if err = checkpoint(); err != nil {
+				break
 			}
 		}
+
+		if cancelled {
+			err = ctx.Err()
+			break
+		}
 	}
 
 	if err != nil && err != io.EOF {
 		return nil, err
 	}
 
-	var b = Builds{
-		From:        from,
-		To:          to,
-		Num:         nBuilds,
-		RateSuccess: float32(nBuilds-nBuildsFailed) / float32(nBuilds),
+	if opts.Progress != nil {
+		progress()
 	}
 
-	sort.Slice(usersNBuilds, func(i, j int) bool {
-		return usersNBuilds[i].n > usersNBuilds[j].n
-	})
+	var b = agg.Builds()
+	if dd != nil {
+		b.NumDuplicates = dd.Dropped()
+	}
 
-	sort.Slice(errCodesNBuilds, func(i, j int) bool {
-		return errCodesNBuilds[i].n > errCodesNBuilds[j].n
-	})
+	if lastGroups != nil {
+		for _, rec := range dd.seen {
+			lastGroups.add(rec)
+		}
 
-	var n = len(usersNBuilds)
-	if n > 5 {
-		n = 5
-	}
-	for i := 0; i < n; i++ {
-		b.TopUsers[i] = usersNBuilds[i].u
+		b.Groups = lastGroups.sorted()
 	}
 
-	n = len(errCodesNBuilds)
-	if n > 5 {
-		n = 5
-	}
-	for i := 0; i < n; i++ {
-		b.TopErrCodes[i] = errCodesNBuilds[i].c
+	if sf != nil {
+		b.Violations = sf.violations
+		b.NumRejected = sf.rejected
 	}
 
-	return &b, nil
+	return b, nil
 }
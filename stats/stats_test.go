@@ -1,6 +1,7 @@
 package stats_test
 
 import (
+	"context"
 	"encoding/csv"
 	"math/rand"
 	"strings"
@@ -28,6 +29,7 @@ func TestNewRecordFromCSV(t *testing.T) {
 					require.NoError(t, terr)
 
 					assert.Equal(t, &stats.Record{
+						BuildID:  "bid1",
 						UserID:   "userE",
 						ExecEnd:  tm,
 						ExitCode: 3,
@@ -43,6 +45,28 @@ func TestNewRecordFromCSV(t *testing.T) {
 				assert.Equal(t, stats.ErrInvalidRecord, err)
 			},
 		},
+		{
+			desc:   "successful: exit code above 127",
+			argRec: []string{"bid1", "userE", "not-used", "not-used", "2018-10-31T11:02:15-04:00", "no-used", "137"},
+			assert: func(t *testing.T, r *stats.Record, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, uint8(137), r.ExitCode)
+			},
+		},
+		{
+			desc:   "error: negative exit code",
+			argRec: []string{"bid1", "userE", "not-used", "not-used", "2018-10-31T11:02:15-04:00", "no-used", "-1"},
+			assert: func(t *testing.T, r *stats.Record, err error) {
+				assert.Equal(t, stats.ErrInvalidRecord, err)
+			},
+		},
+		{
+			desc:   "error: exit code above 255",
+			argRec: []string{"bid1", "userE", "not-used", "not-used", "2018-10-31T11:02:15-04:00", "no-used", "256"},
+			assert: func(t *testing.T, r *stats.Record, err error) {
+				assert.Equal(t, stats.ErrInvalidRecord, err)
+			},
+		},
 		{
 			desc:   "error: invalid exit code",
 			argRec: []string{"bid1", "userE", "not-used", "not-used", "2018-10-31T11:02:15-04:00", "no-used", "no-numeric"},
@@ -71,6 +95,47 @@ func TestNewRecordFromCSV(t *testing.T) {
 	}
 }
 
+func TestNewFullRecordFromCSV(t *testing.T) {
+	t.Run("successful", func(t *testing.T) {
+		var r, err = stats.NewFullRecordFromCSV([]string{
+			"bid1", "userE", "2018-10-31T10:58:00-04:00", "2018-10-31T11:00:15-04:00",
+			"2018-10-31T11:02:15-04:00", "true", "3", "945058189",
+		})
+		require.NoError(t, err)
+
+		var parse = func(v string) time.Time {
+			var tm, err = time.Parse(time.RFC3339, v)
+			require.NoError(t, err)
+			return tm
+		}
+
+		assert.Equal(t, &stats.Record{
+			BuildID:   "bid1",
+			UserID:    "userE",
+			ReqTime:   parse("2018-10-31T10:58:00-04:00"),
+			ExecStart: parse("2018-10-31T11:00:15-04:00"),
+			ExecEnd:   parse("2018-10-31T11:02:15-04:00"),
+			Deleted:   true,
+			ExitCode:  3,
+			ImageSize: 945058189,
+		}, r)
+		assert.Equal(t, 2*time.Minute, r.Duration())
+	})
+
+	t.Run("error: not used fields by NewRecordFromCSV", func(t *testing.T) {
+		var _, err = stats.NewFullRecordFromCSV(strings.Split(recordsUserA[0], ","))
+		assert.Equal(t, stats.ErrInvalidRecord, err)
+	})
+
+	t.Run("error: invalid number of fields", func(t *testing.T) {
+		var _, err = stats.NewFullRecordFromCSV([]string{
+			"bid1", "userE", "2018-10-31T10:58:00-04:00", "2018-10-31T11:00:15-04:00",
+			"2018-10-31T11:02:15-04:00", "true", "3",
+		})
+		assert.Equal(t, stats.ErrInvalidRecord, err)
+	})
+}
+
 func TestComputeBuilds(t *testing.T) {
 	t.Run("successful", func(t *testing.T) {
 		var recOutTWindow = make([]string, rand.Intn(10)+10)
@@ -97,6 +162,19 @@ func TestComputeBuilds(t *testing.T) {
 		assert.Equal(t, &expectedBuilds, b)
 	})
 
+	t.Run("successful: empty time window", func(t *testing.T) {
+		var in = strings.NewReader(strings.Join(recordsUserA, "\n"))
+		var from = expectedBuilds.To.Add(time.Hour)
+		var b, err = stats.ComputeBuilds(csv.NewReader(in), from, from.Add(time.Hour))
+		require.NoError(t, err)
+
+		assert.True(t, b.Empty())
+		assert.True(t, b.SmallSample())
+		assert.Equal(t, float32(0), b.RateSuccess)
+		assert.Equal(t, stats.Interval{Lower: 0, Upper: 1}, b.RateSuccessInterval())
+		assert.Empty(t, b.Users)
+	})
+
 	t.Run("error: invalid record", func(t *testing.T) {
 		t.Skipf("TO BE IMPLEMENTED")
 	})
@@ -105,3 +183,79 @@ func TestComputeBuilds(t *testing.T) {
 		t.Skipf("TO BE IMPLEMENTED")
 	})
 }
+
+func TestComputeBuildsContext(t *testing.T) {
+	var records = append([]string{}, recordsUserA...)
+	records = append(records, recordsUserB...)
+	records = append(records, genRecord(expectedBuilds.To.Add(time.Hour), "userA", 0))
+
+	t.Run("successful: progress", func(t *testing.T) {
+		var (
+			in       = strings.Join(records, "\n")
+			progress []stats.Progress
+		)
+
+		var b, err = stats.ComputeBuildsContext(
+			context.Background(), csv.NewReader(strings.NewReader(in)),
+			expectedBuilds.From, expectedBuilds.To,
+			stats.Options{
+				Progress:         func(p stats.Progress) { progress = append(progress, p) },
+				ProgressInterval: 10,
+			},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(28), b.Num)
+
+		if assert.Len(t, progress, 3) {
+			assert.Equal(t, uint64(10), progress[0].Records)
+			assert.Equal(t, uint64(20), progress[1].Records)
+			assert.Equal(t, stats.Progress{
+				Records: uint64(len(records)),
+				Bytes:   int64(len(in)),
+				Matched: 28,
+			}, progress[2])
+		}
+	})
+
+	t.Run("error: context canceled", func(t *testing.T) {
+		var ctx, cancel = context.WithCancel(context.Background())
+		var read uint64
+
+		var _, err = stats.ComputeBuildsContext(
+			ctx, csv.NewReader(strings.NewReader(strings.Join(records, "\n"))),
+			expectedBuilds.From, expectedBuilds.To,
+			stats.Options{
+				Progress: func(p stats.Progress) {
+					read = p.Records
+					cancel()
+				},
+				ProgressInterval: 5,
+			},
+		)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, uint64(5), read)
+	})
+}
+
+func TestBuilds_Ranked(t *testing.T) {
+	var b = stats.Builds{
+		Users: map[string]stats.Counts{
+			"userB": {Num: 3, NumFailed: 1},
+			"userA": {Num: 3},
+			"userC": {Num: 5, NumFailed: 5},
+		},
+		ErrCodes: map[uint8]uint64{2: 1, 1: 5, 3: 1},
+	}
+
+	assert.Equal(t, []stats.UserBuilds{
+		{UserID: "userC", Counts: stats.Counts{Num: 5, NumFailed: 5}},
+		{UserID: "userA", Counts: stats.Counts{Num: 3}},
+		{UserID: "userB", Counts: stats.Counts{Num: 3, NumFailed: 1}},
+	}, b.RankedUsers())
+
+	assert.Equal(t, []stats.ErrCodeBuilds{
+		{ExitCode: 1, Num: 5},
+		{ExitCode: 2, Num: 1},
+		{ExitCode: 3, Num: 1},
+	}, b.RankedErrCodes())
+}
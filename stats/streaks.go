@@ -0,0 +1,199 @@
+package stats
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// FindingKind identifies the pattern that a Finding matches.
+type FindingKind uint8
+
+// The patterns which FindFlakyUsers looks for.
+const (
+	// FailureStreak is a run of consecutive failed builds.
+	FailureStreak FindingKind = iota + 1
+	// Flapping is a run of builds whose result alternates between success and
+	// failure on each build.
+	Flapping
+)
+
+// String returns a human readable name of the kind.
+func (k FindingKind) String() string {
+	switch k {
+	case FailureStreak:
+		return "failure streak"
+	case Flapping:
+		return "flapping"
+	default:
+		return "unknown"
+	}
+}
+
+// Finding is a run of consecutive builds of a user, ordered by their execution
+// finish time, which matches one of the patterns of FindingKind.
+type Finding struct {
+	Kind     FindingKind
+	UserID   string
+	Start    time.Time
+	End      time.Time
+	BuildIDs []string
+}
+
+// FlakyOptions contains the thresholds used by FindFlakyUsers. A zero
+// threshold disables the detection of its pattern.
+type FlakyOptions struct {
+	// MinFailureStreak is the minimum number of consecutive failed builds for
+	// reporting a FailureStreak.
+	MinFailureStreak int
+	// MinAlternations is the minimum number of consecutive changes between
+	// success and failure for reporting a Flapping run.
+	MinAlternations int
+	// Bounds and Semantics select the records of the time window as in Options.
+	Bounds    Bounds
+	Semantics SemanticOptions
+}
+
+// FindFlakyUsers returns the runs of builds, of r records pending to read
+// considering the passed time window, which match the thresholds of opts.
+// The builds of each user are considered in the order of their execution
+// finish time.
+// Findings are sorted by user ID and then by start time.
+func FindFlakyUsers(r *csv.Reader, from time.Time, to time.Time, opts FlakyOptions) ([]Finding, error) {
+	if opts.MinFailureStreak < 0 || opts.MinAlternations < 0 {
+		return nil, errors.New("Invalid argument. Thresholds cannot be negative")
+	}
+
+	var twr, err = NewTimeWindowReaderBounds(r, from, to, opts.Bounds)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		csvr  []string
+		users = map[string][]*Record{}
+		sf    = newSemanticFilter(r, opts.Semantics)
+	)
+
+	for csvr, err = twr.Read(); err == nil; csvr, err = twr.Read() {
+		var (
+			rec    *Record
+			accept bool
+		)
+
+		rec, accept, err = sf.parse(csvr, NewRecordFromCSV)
+		if err != nil {
+			break
+		}
+
+		if !accept {
+			continue
+		}
+
+		users[rec.UserID] = append(users[rec.UserID], rec)
+	}
+
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var ids = make([]string, 0, len(users))
+	for u := range users {
+		ids = append(ids, u)
+	}
+	sort.Strings(ids)
+
+	var findings []Finding
+	for _, u := range ids {
+		var recs = users[u]
+		sort.SliceStable(recs, func(i, j int) bool {
+			return recs[i].ExecEnd.Before(recs[j].ExecEnd)
+		})
+
+		var uf []Finding
+		if opts.MinFailureStreak > 0 {
+			uf = append(uf, failureStreaks(recs, opts.MinFailureStreak)...)
+		}
+
+		if opts.MinAlternations > 0 {
+			uf = append(uf, flappingRuns(recs, opts.MinAlternations)...)
+		}
+
+		sort.SliceStable(uf, func(i, j int) bool {
+			return uf[i].Start.Before(uf[j].Start)
+		})
+
+		findings = append(findings, uf...)
+	}
+
+	return findings, nil
+}
+
+// failureStreaks returns the runs of at least min consecutive failed builds of
+// recs, which must be sorted and belong to the same user.
+func failureStreaks(recs []*Record, min int) []Finding {
+	var (
+		findings []Finding
+		start    = -1
+	)
+
+	for i := 0; i <= len(recs); i++ {
+		if i < len(recs) && recs[i].ExitCode > 0 {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 && i-start >= min {
+			findings = append(findings, newFinding(FailureStreak, recs[start:i]))
+		}
+
+		start = -1
+	}
+
+	return findings
+}
+
+// flappingRuns returns the runs of recs where the build result changes between
+// success and failure on each build at least min times. recs must be sorted and
+// belong to the same user.
+func flappingRuns(recs []*Record, min int) []Finding {
+	var (
+		findings []Finding
+		start    = 0
+	)
+
+	for i := 1; i <= len(recs); i++ {
+		if i < len(recs) && (recs[i].ExitCode > 0) != (recs[i-1].ExitCode > 0) {
+			continue
+		}
+
+		// The number of alternations of a run is its number of builds minus one.
+		if i-start-1 >= min {
+			findings = append(findings, newFinding(Flapping, recs[start:i]))
+		}
+
+		start = i
+	}
+
+	return findings
+}
+
+func newFinding(k FindingKind, recs []*Record) Finding {
+	var f = Finding{
+		Kind:     k,
+		UserID:   recs[0].UserID,
+		Start:    recs[0].ExecEnd,
+		End:      recs[len(recs)-1].ExecEnd,
+		BuildIDs: make([]string, len(recs)),
+	}
+
+	for i, r := range recs {
+		f.BuildIDs[i] = r.BuildID
+	}
+
+	return f
+}
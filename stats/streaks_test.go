@@ -0,0 +1,103 @@
+package stats_test
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindFlakyUsers(t *testing.T) {
+	var (
+		base    = expectedBuilds.From
+		records []string
+	)
+
+	var add = func(user string, codes ...uint8) {
+		for _, c := range codes {
+			var bt = base.Add(time.Duration(len(records)+1) * time.Minute)
+			records = append(records, fmt.Sprintf(
+				"b%d,%s,not-used,not-used,%s,not-used,%d,no-used",
+				len(records), user, bt.Format(time.RFC3339), c,
+			))
+		}
+	}
+
+	add("userA", 0, 1, 2, 3, 0, 4)    // b0-b5: failure streak b1-b3
+	add("userB", 0, 1, 0, 1, 0, 0, 2) // b6-b12: flapping b6-b10
+	add("userC", 1, 1, 0, 0)          // b13-b16: nothing
+
+	var parseTime = func(rec string) time.Time {
+		var tm, err = time.Parse(time.RFC3339, strings.Split(rec, ",")[4])
+		require.NoError(t, err)
+		return tm
+	}
+
+	t.Run("successful", func(t *testing.T) {
+		// Records are reversed for ensuring that they are sorted by time.
+		var in = make([]string, len(records))
+		for i, r := range records {
+			in[len(records)-1-i] = r
+		}
+
+		var f, err = stats.FindFlakyUsers(
+			csv.NewReader(strings.NewReader(strings.Join(in, "\n"))),
+			expectedBuilds.From, expectedBuilds.To,
+			stats.FlakyOptions{MinFailureStreak: 3, MinAlternations: 4},
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, []stats.Finding{
+			{
+				Kind:     stats.FailureStreak,
+				UserID:   "userA",
+				Start:    parseTime(records[1]),
+				End:      parseTime(records[3]),
+				BuildIDs: []string{"b1", "b2", "b3"},
+			},
+			{
+				Kind:     stats.Flapping,
+				UserID:   "userB",
+				Start:    parseTime(records[6]),
+				End:      parseTime(records[10]),
+				BuildIDs: []string{"b6", "b7", "b8", "b9", "b10"},
+			},
+		}, f)
+	})
+
+	t.Run("successful: disabled patterns", func(t *testing.T) {
+		var f, err = stats.FindFlakyUsers(
+			csv.NewReader(strings.NewReader(strings.Join(records, "\n"))),
+			expectedBuilds.From, expectedBuilds.To,
+			stats.FlakyOptions{},
+		)
+		require.NoError(t, err)
+		assert.Empty(t, f)
+	})
+
+	t.Run("error: negative threshold", func(t *testing.T) {
+		var _, err = stats.FindFlakyUsers(
+			csv.NewReader(strings.NewReader(strings.Join(records, "\n"))),
+			expectedBuilds.From, expectedBuilds.To,
+			stats.FlakyOptions{MinFailureStreak: -1},
+		)
+		assert.Error(t, err)
+	})
+
+	t.Run("error: invalid record", func(t *testing.T) {
+		var in = append([]string{}, records...)
+		in[2] = "b2,userA,not-used,not-used,2018-10-31T04:00:00-04:00,no-used,no-numeric,no-used"
+
+		var _, err = stats.FindFlakyUsers(
+			csv.NewReader(strings.NewReader(strings.Join(in, "\n"))),
+			expectedBuilds.From, expectedBuilds.To,
+			stats.FlakyOptions{MinFailureStreak: 3},
+		)
+		assert.Equal(t, stats.ErrInvalidRecord, err)
+	})
+}
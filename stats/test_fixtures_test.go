@@ -10,6 +10,7 @@ import (
 // Time window: 2018-10-31T03:43:46-04:00 - 2018-11-01T21:25:40-04:00
 // Total builds: 53
 // Builds succeeded: 30
+// Builds failed: 23
 // Top users: [userA, userB, userC, userD, userE]
 // Top error codes: [ 4, 3, 5, 2, 7]
 var expectedBuilds = stats.Builds{
@@ -28,9 +29,19 @@ var expectedBuilds = stats.Builds{
 		return t
 	}(),
 	Num:         53,
+	NumFailed:   23,
 	RateSuccess: 30.0 / 53.0,
 	TopUsers:    [...]string{"userA", "userB", "userC", "userD", "userE"},
 	TopErrCodes: [...]uint8{4, 3, 5, 2, 7},
+	Users: map[string]stats.Counts{
+		"userA": {Num: 15, NumFailed: 5},
+		"userB": {Num: 13, NumFailed: 8},
+		"userC": {Num: 10, NumFailed: 5},
+		"userD": {Num: 8, NumFailed: 2},
+		"userE": {Num: 6, NumFailed: 2},
+		"userF": {Num: 1, NumFailed: 1},
+	},
+	ErrCodes: map[uint8]uint64{1: 1, 2: 3, 3: 5, 4: 6, 5: 4, 6: 1, 7: 2, 8: 1},
 }
 
 // Num: 15
@@ -0,0 +1,191 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Granularity is the length of the buckets of a timeline.
+type Granularity uint8
+
+// The granularities of a timeline.
+const (
+	// GranularityNone disables the timeline.
+	GranularityNone Granularity = iota
+	// GranularityAuto selects hours, days or weeks depending on the time span of
+	// the records, for having a reasonable number of buckets.
+	GranularityAuto
+	GranularityHour
+	GranularityDay
+	GranularityWeek
+)
+
+// The maximum time spans of the records which GranularityAuto resolves to hours
+// and days; longer spans resolve to weeks.
+const (
+	autoHourMaxSpan = 72 * time.Hour
+	autoDayMaxSpan  = 92 * 24 * time.Hour
+)
+
+// String returns the name of the granularity, which ParseGranularity accepts.
+func (g Granularity) String() string {
+	switch g {
+	case GranularityNone:
+		return "none"
+	case GranularityAuto:
+		return "auto"
+	case GranularityHour:
+		return "hour"
+	case GranularityDay:
+		return "day"
+	case GranularityWeek:
+		return "week"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseGranularity returns the granularity whose String method returns name.
+func ParseGranularity(name string) (Granularity, error) {
+	for g := GranularityNone; g <= GranularityWeek; g++ {
+		if g.String() == name {
+			return g, nil
+		}
+	}
+
+	return GranularityNone, fmt.Errorf("Invalid granularity %q. Valid ones are: none, auto, hour, day, week", name)
+}
+
+// TimelineBucket contains the number of builds whose execution finished in
+// the time range which starts at Start and has the length of the timeline
+// granularity.
+type TimelineBucket struct {
+	Start time.Time
+	Counts
+}
+
+// hourBuckets accumulates the records by the hour of their execution finish
+// time, which is the smallest granularity, so they can be merged later in
+// buckets of any Granularity. The hours are the ones of a location, which must
+// be the same for all the operations.
+type hourBuckets map[int64]Counts
+
+func (hb hourBuckets) add(rec *Record, loc *time.Location) {
+	var (
+		k = bucketStart(rec.ExecEnd.In(loc), GranularityHour).Unix()
+		c = hb[k]
+	)
+
+	c.Num++
+	if rec.ExitCode > 0 {
+		c.NumFailed++
+	}
+
+	hb[k] = c
+}
+
+func (hb hourBuckets) remove(rec *Record, loc *time.Location) {
+	var (
+		k = bucketStart(rec.ExecEnd.In(loc), GranularityHour).Unix()
+		c = hb[k]
+	)
+
+	c.Num--
+	if rec.ExitCode > 0 {
+		c.NumFailed--
+	}
+
+	if c.Num == 0 {
+		delete(hb, k)
+	} else {
+		hb[k] = c
+	}
+}
+
+// timeline returns the buckets of g in loc, which cannot be GranularityNone,
+// from the first bucket with builds to the last one, including the empty
+// buckets between them.
+func (hb hourBuckets) timeline(g Granularity, loc *time.Location) (Granularity, []TimelineBucket) {
+	if len(hb) == 0 {
+		if g == GranularityAuto {
+			g = GranularityHour
+		}
+
+		return g, []TimelineBucket{}
+	}
+
+	var hours = make([]int64, 0, len(hb))
+	for h := range hb {
+		hours = append(hours, h)
+	}
+	sort.Slice(hours, func(i, j int) bool { return hours[i] < hours[j] })
+
+	var (
+		first = time.Unix(hours[0], 0).In(loc)
+		last  = time.Unix(hours[len(hours)-1], 0).In(loc)
+	)
+
+	if g == GranularityAuto {
+		switch span := last.Sub(first); {
+		case span <= autoHourMaxSpan:
+			g = GranularityHour
+		case span <= autoDayMaxSpan:
+			g = GranularityDay
+		default:
+			g = GranularityWeek
+		}
+	}
+
+	var buckets []TimelineBucket
+	for s := bucketStart(first, g); !s.After(last); s = nextBucketStart(s, g) {
+		buckets = append(buckets, TimelineBucket{Start: s})
+	}
+
+	for _, h := range hours {
+		var (
+			t = time.Unix(h, 0)
+			i = sort.Search(len(buckets), func(i int) bool {
+				return buckets[i].Start.After(t)
+			}) - 1
+			c = hb[h]
+		)
+
+		buckets[i].Num += c.Num
+		buckets[i].NumFailed += c.NumFailed
+	}
+
+	return g, buckets
+}
+
+// bucketStart returns the start of the bucket of g which t belongs to in the
+// location of t. Weeks start on Monday.
+func bucketStart(t time.Time, g Granularity) time.Time {
+	switch g {
+	case GranularityDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case GranularityWeek:
+		var d = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+	default:
+		// Truncating the time shifted by its offset works for the locations
+		// whose offset isn't a whole number of hours and, unlike time.Date, it
+		// doesn't mix up the repeated hour when the daylight saving time ends.
+		var _, off = t.Zone()
+		var d = time.Duration(off) * time.Second
+		return t.Add(d).Truncate(time.Hour).Add(-d)
+	}
+}
+
+// nextBucketStart returns the start of the bucket of g which follows the one
+// which starts at s.
+func nextBucketStart(s time.Time, g Granularity) time.Time {
+	switch g {
+	case GranularityDay:
+		return s.AddDate(0, 0, 1)
+	case GranularityWeek:
+		return s.AddDate(0, 0, 7)
+	default:
+		return s.Add(time.Hour)
+	}
+}
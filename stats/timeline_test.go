@@ -0,0 +1,115 @@
+package stats_test
+
+import (
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeBuildsContext_Timeline(t *testing.T) {
+	var compute = func(t *testing.T, g stats.Granularity) *stats.Builds {
+		var in = strings.NewReader(strings.Join(recordsUserA, "\n"))
+		var b, err = stats.ComputeBuildsContext(
+			context.Background(), csv.NewReader(in), expectedBuilds.From, expectedBuilds.To,
+			stats.Options{Timeline: g},
+		)
+		require.NoError(t, err)
+		return b
+	}
+
+	t.Run("none", func(t *testing.T) {
+		var b = compute(t, stats.GranularityNone)
+		assert.Nil(t, b.Timeline)
+		assert.Equal(t, stats.GranularityNone, b.TimelineGranularity)
+	})
+
+	t.Run("day", func(t *testing.T) {
+		var b = compute(t, stats.GranularityDay)
+		assert.Equal(t, stats.GranularityDay, b.TimelineGranularity)
+		assert.Equal(t, []stats.TimelineBucket{
+			{
+				Start:  time.Date(2018, 10, 31, 0, 0, 0, 0, time.UTC),
+				Counts: stats.Counts{Num: 10, NumFailed: 5},
+			},
+			{
+				Start:  time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC),
+				Counts: stats.Counts{Num: 5},
+			},
+		}, b.Timeline)
+	})
+
+	t.Run("week", func(t *testing.T) {
+		var b = compute(t, stats.GranularityWeek)
+		assert.Equal(t, []stats.TimelineBucket{
+			{
+				Start:  time.Date(2018, 10, 29, 0, 0, 0, 0, time.UTC),
+				Counts: stats.Counts{Num: 15, NumFailed: 5},
+			},
+		}, b.Timeline)
+	})
+
+	t.Run("auto", func(t *testing.T) {
+		var b = compute(t, stats.GranularityAuto)
+		assert.Equal(t, stats.GranularityHour, b.TimelineGranularity)
+		if assert.Len(t, b.Timeline, 25) {
+			assert.Equal(t, stats.TimelineBucket{
+				Start:  time.Date(2018, 10, 31, 9, 0, 0, 0, time.UTC),
+				Counts: stats.Counts{Num: 1},
+			}, b.Timeline[0])
+			assert.Equal(t, stats.TimelineBucket{
+				Start: time.Date(2018, 10, 31, 12, 0, 0, 0, time.UTC),
+			}, b.Timeline[3])
+		}
+
+		var n uint64
+		for _, bk := range b.Timeline {
+			n += bk.Num
+		}
+		assert.Equal(t, b.Num, n)
+	})
+}
+
+func TestParseGranularity(t *testing.T) {
+	for _, g := range []stats.Granularity{
+		stats.GranularityNone, stats.GranularityAuto, stats.GranularityHour,
+		stats.GranularityDay, stats.GranularityWeek,
+	} {
+		var pg, err = stats.ParseGranularity(g.String())
+		assert.NoError(t, err)
+		assert.Equal(t, g, pg)
+	}
+
+	var _, err = stats.ParseGranularity("month")
+	assert.Error(t, err)
+}
+
+func TestComputeBuildsContext_TimelineLocation(t *testing.T) {
+	var loc, err = time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	var in = strings.NewReader(strings.Join([]string{
+		genRecord(time.Date(2018, 10, 31, 23, 20, 0, 0, loc), "userA", 0),
+		genRecord(time.Date(2018, 11, 1, 0, 10, 0, 0, loc), "userA", 1),
+		genRecord(time.Date(2018, 11, 1, 0, 50, 0, 0, loc), "userA", 0),
+	}, "\n"))
+
+	b, err := stats.ComputeBuildsContext(
+		context.Background(), csv.NewReader(in), time.Time{}, time.Now(),
+		stats.Options{Timeline: stats.GranularityHour, Location: loc},
+	)
+	require.NoError(t, err)
+
+	if assert.Len(t, b.Timeline, 2) {
+		assert.True(t, time.Date(2018, 10, 31, 23, 0, 0, 0, loc).Equal(b.Timeline[0].Start))
+		assert.Equal(t, stats.Counts{Num: 1}, b.Timeline[0].Counts)
+		assert.True(t, time.Date(2018, 11, 1, 0, 0, 0, 0, loc).Equal(b.Timeline[1].Start))
+		assert.Equal(t, stats.Counts{Num: 2, NumFailed: 1}, b.Timeline[1].Counts)
+		assert.Equal(t, loc, b.Timeline[1].Start.Location())
+	}
+}
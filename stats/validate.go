@@ -0,0 +1,277 @@
+package stats
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// NumFields is the number of fields of the records of the remote build service
+// CSV exports.
+const NumFields = 8
+
+// Rule is a check of the records of the remote build service CSV exports, see
+// Validator.
+type Rule uint8
+
+// The rules checked by Validator; the ones of SemanticRules are only checked
+// when their action isn't ActionIgnore.
+const (
+	// RuleSyntax is violated by the records which aren't valid CSV, e.g. they
+	// have a bare quote.
+	RuleSyntax Rule = iota + 1
+	// RuleFieldCount is violated by the records which don't have NumFields
+	// fields; the rest of the rules aren't checked for them.
+	RuleFieldCount
+	// RuleTimestamp is violated by the request, execution start and execution
+	// end times which aren't RFC 3339.
+	RuleTimestamp
+	// RuleDeleted is violated by the deleted flags which aren't a boolean.
+	RuleDeleted
+	// RuleExitCode is violated by the exit codes which aren't an integer
+	// between 0 and 255.
+	RuleExitCode
+	// RuleImageSize is violated by the image sizes which aren't an integer.
+	RuleImageSize
+	// RuleUniqueBuildID is violated by the records whose build ID is the one of
+	// a previous record.
+	RuleUniqueBuildID
+	// RuleSortOrder is violated by the records requested before the previous
+	// record, because the exports are sorted by the request time.
+	RuleSortOrder
+	// RuleStartBeforeRequest is violated by the records whose execution starts
+	// before being requested.
+	RuleStartBeforeRequest
+	// RuleEndBeforeStart is violated by the records whose execution finishes
+	// before starting.
+	RuleEndBeforeStart
+	// RuleFutureTime is violated by the times which are in the future.
+	RuleFutureTime
+	// RuleNegativeSize is violated by the negative image sizes.
+	RuleNegativeSize
+	// RuleDeletedVocabulary is violated by the deleted flags which aren't any of
+	// the DeletedVocabulary.
+	RuleDeletedVocabulary
+)
+
+// Rules contains all the rules in the order that they are checked.
+var Rules = []Rule{
+	RuleSyntax, RuleFieldCount, RuleTimestamp, RuleDeleted, RuleExitCode, RuleImageSize, RuleUniqueBuildID,
+	RuleSortOrder, RuleStartBeforeRequest, RuleEndBeforeStart, RuleFutureTime, RuleNegativeSize,
+	RuleDeletedVocabulary,
+}
+
+// String returns the name of the rule.
+func (r Rule) String() string {
+	switch r {
+	case RuleSyntax:
+		return "syntax"
+	case RuleFieldCount:
+		return "field-count"
+	case RuleTimestamp:
+		return "timestamp"
+	case RuleDeleted:
+		return "deleted"
+	case RuleExitCode:
+		return "exit-code"
+	case RuleImageSize:
+		return "image-size"
+	case RuleUniqueBuildID:
+		return "unique-build-id"
+	case RuleSortOrder:
+		return "sort-order"
+	case RuleStartBeforeRequest:
+		return "start-before-request"
+	case RuleEndBeforeStart:
+		return "end-before-start"
+	case RuleFutureTime:
+		return "future-time"
+	case RuleNegativeSize:
+		return "negative-size"
+	case RuleDeletedVocabulary:
+		return "deleted-vocabulary"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseRule returns the rule whose String method returns name.
+func ParseRule(name string) (Rule, error) {
+	for _, r := range Rules {
+		if r.String() == name {
+			return r, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Invalid rule %q", name)
+}
+
+// Violation is a record which doesn't satisfy a Rule.
+type Violation struct {
+	// Line is the line where the record starts.
+	Line int
+	Rule Rule
+	// Action is the one of the rule, which is always ActionReject for the
+	// rules which aren't SemanticRules.
+	Action  Action
+	Message string
+	// Related is the line of the previous record involved in the violation,
+	// e.g. the one with the same build ID; it's 0 if there isn't any.
+	Related int
+}
+
+// Validator checks the records of a CSV export one by one, remembering what the
+// rules which involve several records need.
+type Validator struct {
+	semantics  SemanticOptions
+	now        time.Time
+	buildLines map[string]int
+	// lastReq is the request time of the last record which has it, on
+	// lastReqLine.
+	lastReq     time.Time
+	lastReqLine int
+}
+
+// NewValidator returns a Validator for the records of one CSV export, which
+// checks the SemanticRules as indicated by so; its Report function isn't used.
+func NewValidator(so SemanticOptions) *Validator {
+	return &Validator{semantics: so, now: so.now(), buildLines: map[string]int{}}
+}
+
+// Check returns the violations of rec, which starts on line; it must be called
+// with the records in the order of the export.
+func (v *Validator) Check(line int, rec []string) []Violation {
+	var violations []Violation
+	var violate = func(r Rule, related int, format string, args ...interface{}) {
+		violations = append(violations, Violation{
+			Line: line, Rule: r, Action: ActionReject, Message: fmt.Sprintf(format, args...), Related: related,
+		})
+	}
+
+	if len(rec) != NumFields {
+		violate(RuleFieldCount, 0, "Record has %d fields instead of %d", len(rec), NumFields)
+		return violations
+	}
+
+	// The fields which fail to parse are zero, so the semantic rules don't
+	// check them.
+	var (
+		names  = [3]string{"request", "execution start", "execution end"}
+		times  [3]time.Time
+		parsed = Record{BuildID: rec[0], UserID: rec[1]}
+		err    error
+	)
+
+	for i := range times {
+		if times[i], err = time.Parse(time.RFC3339, rec[2+i]); err != nil {
+			violate(RuleTimestamp, 0, "Invalid %s time %q, it must be RFC 3339", names[i], rec[2+i])
+		}
+	}
+	parsed.ReqTime, parsed.ExecStart, parsed.ExecEnd = times[0], times[1], times[2]
+
+	var deletedErr error
+	if parsed.Deleted, deletedErr = strconv.ParseBool(rec[5]); deletedErr != nil {
+		violate(RuleDeleted, 0, "Invalid deleted flag %q, it must be a boolean", rec[5])
+	}
+
+	if _, err := ParseExitCode(rec[6]); err != nil {
+		violate(RuleExitCode, 0, "Invalid exit code %q, it must be an integer between 0 and 255", rec[6])
+	}
+
+	if parsed.ImageSize, err = strconv.ParseInt(rec[7], 10, 64); err != nil {
+		violate(RuleImageSize, 0, "Invalid image size %q, it must be an integer", rec[7])
+	}
+
+	if first, ok := v.buildLines[rec[0]]; ok {
+		violate(RuleUniqueBuildID, first, "Build ID %q is repeated", rec[0])
+	} else {
+		v.buildLines[rec[0]] = line
+	}
+
+	if !times[0].IsZero() {
+		if times[0].Before(v.lastReq) {
+			violate(RuleSortOrder, v.lastReqLine, "Request time %s is before the one of a previous record, %s",
+				rec[2], v.lastReq.Format(time.RFC3339))
+		} else {
+			v.lastReq = times[0]
+			v.lastReqLine = line
+		}
+	}
+
+	for _, sv := range v.semantics.check(line, &parsed, rec[5], v.now) {
+		// The deleted flags which aren't a boolean already violate RuleDeleted.
+		if sv.Rule == RuleDeletedVocabulary && deletedErr != nil {
+			continue
+		}
+
+		violations = append(violations, sv)
+	}
+
+	return violations
+}
+
+// ValidationSummary contains the results of Validate.
+type ValidationSummary struct {
+	Records uint64
+	// InvalidRecords is the number of records with at least one violation of a
+	// rule whose action is ActionReject.
+	InvalidRecords uint64
+	// Violations is the number of violations of each rule.
+	Violations map[Rule]uint64
+}
+
+// Valid reports if all the records are valid.
+func (s ValidationSummary) Valid() bool {
+	return s.InvalidRecords == 0
+}
+
+// Validate checks all the pending records of r with a Validator which checks
+// the SemanticRules as indicated by so, calling report with each violation,
+// including the ones of the rules whose action is ActionWarn. It sets
+// r.FieldsPerRecord to -1 because the number of fields is checked by
+// RuleFieldCount, and it only returns the errors of r which aren't a
+// csv.ParseError; those violate RuleSyntax.
+func Validate(r *csv.Reader, so SemanticOptions, report func(Violation)) (ValidationSummary, error) {
+	var (
+		v = NewValidator(so)
+		s = ValidationSummary{Violations: map[Rule]uint64{}}
+	)
+
+	r.FieldsPerRecord = -1
+	for {
+		var rec, err = r.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var violations []Violation
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return s, err
+			}
+
+			violations = []Violation{{Line: perr.StartLine, Rule: RuleSyntax, Action: ActionReject, Message: perr.Err.Error()}}
+		} else {
+			var line, _ = r.FieldPos(0)
+			violations = v.Check(line, rec)
+		}
+
+		var invalid bool
+		for _, vl := range violations {
+			s.Violations[vl.Rule]++
+			invalid = invalid || vl.Action == ActionReject
+			report(vl)
+		}
+
+		s.Records++
+		if invalid {
+			s.InvalidRecords++
+		}
+	}
+
+	return s, nil
+}
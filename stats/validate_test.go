@@ -0,0 +1,190 @@
+package stats_test
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator_Check(t *testing.T) {
+	var tcases = []struct {
+		desc     string
+		rec      string
+		expected []stats.Rule
+	}{
+		{
+			desc: "valid",
+			rec:  "b1,userA,2018-10-31T10:00:00-04:00,2018-10-31T10:01:00-04:00,2018-10-31T10:11:00-04:00,false,0,50000000",
+		},
+		{
+			desc:     "field count",
+			rec:      "b1,userA,2018-10-31T10:00:00-04:00,2018-10-31T10:01:00-04:00,2018-10-31T10:11:00-04:00,false,0",
+			expected: []stats.Rule{stats.RuleFieldCount},
+		},
+		{
+			desc:     "timestamps",
+			rec:      "b1,userA,2018-10-31,2018-10-31T10:01:00-04:00,yesterday,false,0,50000000",
+			expected: []stats.Rule{stats.RuleTimestamp, stats.RuleTimestamp},
+		},
+		{
+			desc:     "execution start before request",
+			rec:      "b1,userA,2018-10-31T10:00:00-04:00,2018-10-31T09:59:00-04:00,2018-10-31T10:11:00-04:00,false,0,50000000",
+			expected: []stats.Rule{stats.RuleStartBeforeRequest},
+		},
+		{
+			desc:     "execution end before start",
+			rec:      "b1,userA,2018-10-31T10:00:00-04:00,2018-10-31T10:01:00-04:00,2018-10-31T10:00:30-04:00,false,0,50000000",
+			expected: []stats.Rule{stats.RuleEndBeforeStart},
+		},
+		{
+			desc:     "future times, negative size and deleted flag out of the vocabulary",
+			rec:      "b1,userA,2018-10-31T10:00:00-04:00,2018-10-31T10:01:00-04:00,2030-10-31T10:11:00-04:00,1,0,-5",
+			expected: []stats.Rule{stats.RuleFutureTime, stats.RuleNegativeSize, stats.RuleDeletedVocabulary},
+		},
+		{
+			desc:     "semantic rules aren't checked with invalid fields",
+			rec:      "b1,userA,2018-10-31T10:00:00-04:00,2018-10-31,2018-10-31T09:00:00-04:00,yes,0,-5",
+			expected: []stats.Rule{stats.RuleTimestamp, stats.RuleDeleted, stats.RuleNegativeSize},
+		},
+		{
+			desc:     "deleted, exit code and image size",
+			rec:      "b1,userA,2018-10-31T10:00:00-04:00,2018-10-31T10:01:00-04:00,2018-10-31T10:11:00-04:00,no,256,5MB",
+			expected: []stats.Rule{stats.RuleDeleted, stats.RuleExitCode, stats.RuleImageSize},
+		},
+	}
+
+	var so = stats.SemanticOptions{
+		Actions: map[stats.Rule]stats.Action{},
+		Now:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for _, r := range stats.SemanticRules {
+		so.Actions[r] = stats.ActionReject
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var rules []stats.Rule
+			for _, v := range stats.NewValidator(so).Check(1, strings.Split(tc.rec, ",")) {
+				assert.Equal(t, 1, v.Line)
+				assert.Equal(t, stats.ActionReject, v.Action)
+				assert.NotEmpty(t, v.Message)
+				rules = append(rules, v.Rule)
+			}
+
+			assert.Equal(t, tc.expected, rules)
+		})
+	}
+
+	t.Run("semantic rules are ignored by default", func(t *testing.T) {
+		var rec = "b1,userA,2018-10-31T10:00:00-04:00,2018-10-31T09:59:00-04:00,2030-10-31T10:11:00-04:00,1,0,-5"
+		assert.Empty(t, stats.NewValidator(stats.SemanticOptions{}).Check(1, strings.Split(rec, ",")))
+	})
+}
+
+func TestValidate(t *testing.T) {
+	var records = []string{
+		groupRecords[0],
+		groupRecords[2],
+		// Requested before the previous record.
+		groupRecords[1],
+		`b6,"userA,2018`,
+	}
+
+	var (
+		in         = strings.Join(append(records, groupRecords[2]), "\n")
+		violations []stats.Violation
+	)
+
+	var s, err = stats.Validate(csv.NewReader(strings.NewReader(in)), stats.SemanticOptions{}, func(v stats.Violation) {
+		violations = append(violations, v)
+	})
+	require.NoError(t, err)
+
+	assert.False(t, s.Valid())
+	// The unterminated quote swallows the last record.
+	assert.Equal(t, uint64(4), s.Records)
+	assert.Equal(t, uint64(2), s.InvalidRecords)
+	assert.Equal(t, map[stats.Rule]uint64{stats.RuleSortOrder: 1, stats.RuleSyntax: 1}, s.Violations)
+	assert.Equal(t, []stats.Violation{
+		{Line: 3, Rule: stats.RuleSortOrder, Action: stats.ActionReject, Message: violations[0].Message, Related: 2},
+		{Line: 4, Rule: stats.RuleSyntax, Action: stats.ActionReject, Message: violations[1].Message},
+	}, violations)
+
+	t.Run("unique build IDs", func(t *testing.T) {
+		var in = strings.Join(append(groupRecords, groupRecords[4]), "\n")
+		var violations []stats.Violation
+		var s, err = stats.Validate(csv.NewReader(strings.NewReader(in)), stats.SemanticOptions{}, func(v stats.Violation) {
+			violations = append(violations, v)
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(6), s.Records)
+		assert.Equal(t, uint64(1), s.InvalidRecords)
+		if assert.Len(t, violations, 1) {
+			assert.Equal(t, stats.RuleUniqueBuildID, violations[0].Rule)
+			assert.Equal(t, 6, violations[0].Line)
+			assert.Equal(t, 5, violations[0].Related)
+		}
+	})
+
+	t.Run("warnings", func(t *testing.T) {
+		var (
+			in = strings.Join(append(groupRecords,
+				"b6,userA,2018-11-01T10:00:00-04:00,2018-11-01T09:59:00-04:00,2018-11-01T10:11:00-04:00,false,0,-1",
+			), "\n")
+			so = stats.SemanticOptions{Actions: map[stats.Rule]stats.Action{
+				stats.RuleStartBeforeRequest: stats.ActionWarn,
+				stats.RuleNegativeSize:       stats.ActionReject,
+			}}
+			actions []stats.Action
+		)
+
+		var s, err = stats.Validate(csv.NewReader(strings.NewReader(in)), so, func(v stats.Violation) {
+			actions = append(actions, v.Action)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []stats.Action{stats.ActionWarn, stats.ActionReject}, actions)
+		assert.Equal(t, uint64(1), s.InvalidRecords)
+
+		so.Actions[stats.RuleNegativeSize] = stats.ActionWarn
+		s, err = stats.Validate(csv.NewReader(strings.NewReader(in)), so, func(v stats.Violation) {})
+		require.NoError(t, err)
+		assert.True(t, s.Valid())
+		assert.Equal(t, map[stats.Rule]uint64{stats.RuleStartBeforeRequest: 1, stats.RuleNegativeSize: 1}, s.Violations)
+	})
+
+	t.Run("exit code killed by a signal", func(t *testing.T) {
+		// 137 is the exit code of a build killed with SIGKILL; the records which
+		// are valid must be computed by the stats too.
+		var in = "b1,userA,2018-10-31T10:00:00-04:00,2018-10-31T10:01:00-04:00,2018-10-31T10:11:00-04:00,false,137,50000000"
+		var s, err = stats.Validate(csv.NewReader(strings.NewReader(in)), stats.SemanticOptions{}, func(v stats.Violation) {
+			t.Errorf("unexpected violation: %+v", v)
+		})
+		require.NoError(t, err)
+		assert.True(t, s.Valid())
+
+		b, err := stats.ComputeBuilds(
+			csv.NewReader(strings.NewReader(in)),
+			time.Date(2018, 10, 31, 0, 0, 0, 0, time.UTC), time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, map[uint8]uint64{137: 1}, b.ErrCodes)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var s, err = stats.Validate(csv.NewReader(strings.NewReader(strings.Join(groupRecords, "\n"))), stats.SemanticOptions{}, func(v stats.Violation) {
+			t.Errorf("unexpected violation: %+v", v)
+		})
+		require.NoError(t, err)
+		assert.True(t, s.Valid())
+		assert.Equal(t, uint64(5), s.Records)
+	})
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// table is a titled set of rows with the same columns, used by the tabular
+// output formats.
+type table struct {
+	// Name identifies the table for selecting it from the command line.
+	Name   string
+	Title  string
+	Header []string
+	Rows   [][]string
+}
+
+// tableFormatFunc writes t to w in some specific format.
+type tableFormatFunc func(w io.Writer, t table) error
+
+// tableFormats contains the output formats of the commands which only output a
+// table, indexed by the name used for selecting them from the command line.
+var tableFormats = map[string]tableFormatFunc{
+	"text": writeTextTable,
+	"csv": func(w io.Writer, t table) error {
+		return writeDelimited(w, []table{t}, ',')
+	},
+	"tsv": func(w io.Writer, t table) error {
+		return writeDelimited(w, []table{t}, '\t')
+	},
+	"markdown": func(w io.Writer, t table) error {
+		return writeMarkdownTables(w, []table{t})
+	},
+}
+
+// reportTables returns the tables with the data of rep.
+func reportTables(rep report) []table {
+	var (
+		b   = rep.Builds
+		all = stats.Counts{Num: b.Num, NumFailed: b.NumFailed}
+		iv  = all.RateSuccessInterval()
+	)
+
+	var tables = []table{
+		{
+			Name:   "summary",
+			Title:  "Summary",
+			Header: []string{"metric", "value"},
+			Rows: [][]string{
+				{"window_from", b.From.Format(time.RFC3339)},
+				{"window_to", b.To.Format(time.RFC3339)},
+				{"window_bounds", b.Bounds.String()},
+				{"builds", strconv.FormatUint(b.Num, 10)},
+				{"succeeded", strconv.FormatUint(b.Num-b.NumFailed, 10)},
+				{"failed", strconv.FormatUint(b.NumFailed, 10)},
+				{"success_rate", rateCell(all)},
+				{"success_rate_ci95_lower", fmtRatio(iv.Lower)},
+				{"success_rate_ci95_upper", fmtRatio(iv.Upper)},
+				{"small_sample", strconv.FormatBool(all.SmallSample())},
+			},
+		},
+	}
+
+	if rep.Dedup != stats.DedupNone {
+		tables[0].Rows = append(tables[0].Rows,
+			[]string{"dedup_policy", rep.Dedup.String()},
+			[]string{"duplicates_dropped", strconv.FormatUint(b.NumDuplicates, 10)},
+		)
+	}
+
+	if b.Violations != nil {
+		tables[0].Rows = append(tables[0].Rows, []string{"rejected_records", strconv.FormatUint(b.NumRejected, 10)})
+		for _, r := range stats.SemanticRules {
+			tables[0].Rows = append(tables[0].Rows,
+				[]string{"violations_" + strings.ReplaceAll(r.String(), "-", "_"), strconv.FormatUint(b.Violations[r], 10)},
+			)
+		}
+	}
+
+	var users, codes = b.RankedUsers(), b.RankedErrCodes()
+	tables = append(tables,
+		usersTable("top-users", "Top 5 users", users, 5),
+		exitCodesTable("top-exit-codes", "Top 5 error exit codes", codes, 5),
+		usersTable("users", "Builds per user", users, len(users)),
+		exitCodesTable("exit-codes", "Failed builds per exit code", codes, len(codes)),
+	)
+
+	if b.TimelineGranularity != stats.GranularityNone {
+		tables = append(tables, timelineTable(b))
+	}
+
+	if rep.GroupBy != "" {
+		tables = append(tables, groupsTable(rep.GroupBy, rep.Groups))
+	}
+
+	if rep.Outreach {
+		var t = table{
+			Name:   "outreach",
+			Title:  "Users to reach out",
+			Header: []string{"user", "kind", "start", "end", "builds", "build_ids"},
+		}
+
+		for _, f := range rep.Findings {
+			t.Rows = append(t.Rows, []string{
+				f.UserID,
+				f.Kind.String(),
+				f.Start.Format(time.RFC3339),
+				f.End.Format(time.RFC3339),
+				strconv.Itoa(len(f.BuildIDs)),
+				strings.Join(f.BuildIDs, " "),
+			})
+		}
+
+		tables = append(tables, t)
+	}
+
+	return tables
+}
+
+func timelineTable(b stats.Builds) table {
+	var t = table{
+		Name:   "timeline",
+		Title:  "Builds per " + b.TimelineGranularity.String(),
+		Header: []string{"start", "builds", "failed", "success_rate"},
+	}
+
+	for _, bk := range b.Timeline {
+		t.Rows = append(t.Rows, []string{
+			bk.Start.Format(time.RFC3339),
+			strconv.FormatUint(bk.Num, 10),
+			strconv.FormatUint(bk.NumFailed, 10),
+			rateCell(bk.Counts),
+		})
+	}
+
+	return t
+}
+
+func groupsTable(dim string, groups []stats.Group) table {
+	var t = table{
+		Name:   "groups",
+		Title:  "Builds by " + dim,
+		Header: []string{dim, "builds", "failed", "success_rate", "min_duration_seconds", "mean_duration_seconds", "max_duration_seconds"},
+	}
+
+	for _, g := range groups {
+		t.Rows = append(t.Rows, []string{
+			g.Key,
+			strconv.FormatUint(g.Counts.Num, 10),
+			strconv.FormatUint(g.Counts.NumFailed, 10),
+			rateCell(g.Counts),
+			fmtSeconds(g.Durations.Min),
+			fmtSeconds(g.Durations.Mean()),
+			fmtSeconds(g.Durations.Max),
+		})
+	}
+
+	return t
+}
+
+func usersTable(name string, title string, users []stats.UserBuilds, n int) table {
+	var t = table{
+		Name:   name,
+		Title:  title,
+		Header: []string{"rank", "user", "builds", "failed", "success_rate"},
+	}
+
+	for i := 0; i < n && i < len(users); i++ {
+		t.Rows = append(t.Rows, []string{
+			strconv.Itoa(i + 1),
+			users[i].UserID,
+			strconv.FormatUint(users[i].Num, 10),
+			strconv.FormatUint(users[i].NumFailed, 10),
+			rateCell(users[i].Counts),
+		})
+	}
+
+	return t
+}
+
+func exitCodesTable(name string, title string, codes []stats.ErrCodeBuilds, n int) table {
+	var t = table{
+		Name:   name,
+		Title:  title,
+		Header: []string{"rank", "exit_code", "builds"},
+	}
+
+	for i := 0; i < n && i < len(codes); i++ {
+		t.Rows = append(t.Rows, []string{
+			strconv.Itoa(i + 1),
+			strconv.Itoa(int(codes[i].ExitCode)),
+			strconv.FormatUint(codes[i].Num, 10),
+		})
+	}
+
+	return t
+}
+
+// rateCell returns the success rate of c as a ratio or an empty string if c
+// doesn't have builds.
+func rateCell(c stats.Counts) string {
+	if c.Num == 0 {
+		return ""
+	}
+
+	return fmtRatio(float64(c.Num-c.NumFailed) / float64(c.Num))
+}
+
+func fmtRatio(r float64) string {
+	return strconv.FormatFloat(r, 'f', 4, 64)
+}
+
+func fmtSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// selectTables returns the table of tables whose name is name, or all of them
+// if name is empty.
+func selectTables(tables []table, name string) ([]table, error) {
+	if name == "" {
+		return tables, nil
+	}
+
+	for _, t := range tables {
+		if t.Name == name {
+			return []table{t}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("The report doesn't have a table named %q", name)
+}
+
+// writeDelimited writes tables as comma delimited values. Each table is
+// preceded by a row with its title and followed by an empty line, unless only
+// one table is written.
+func writeDelimited(w io.Writer, tables []table, comma rune) error {
+	var cw = csv.NewWriter(w)
+	cw.Comma = comma
+
+	for i, t := range tables {
+		if len(tables) > 1 {
+			if i > 0 {
+				if err := cw.Write(nil); err != nil {
+					return err
+				}
+			}
+
+			if err := cw.Write([]string{t.Title}); err != nil {
+				return err
+			}
+		}
+
+		if err := cw.Write(t.Header); err != nil {
+			return err
+		}
+
+		if err := cw.WriteAll(t.Rows); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeCSV(w io.Writer, rep report) error {
+	var tables, err = selectTables(reportTables(rep), rep.Table)
+	if err != nil {
+		return err
+	}
+
+	return writeDelimited(w, tables, ',')
+}
+
+func writeTSV(w io.Writer, rep report) error {
+	var tables, err = selectTables(reportTables(rep), rep.Table)
+	if err != nil {
+		return err
+	}
+
+	return writeDelimited(w, tables, '\t')
+}
+
+// writeTextTable writes t under its title with its columns aligned.
+func writeTextTable(w io.Writer, t table) error {
+	fmt.Fprintf(w, "%s\n%s\n", t.Title, strings.Repeat("=", len(t.Title)))
+	if len(t.Rows) == 0 {
+		_, err := fmt.Fprintln(w, "None")
+		return err
+	}
+
+	var tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.Header, "\t"))
+	for _, r := range t.Rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+
+	return tw.Flush()
+}
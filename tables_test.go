@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDelimited(t *testing.T) {
+	for i := range testWindows {
+		var tw = testWindows[i]
+		for _, f := range []struct {
+			ext   string
+			write formatFunc
+		}{
+			{ext: ".csv", write: writeCSV},
+			{ext: ".tsv", write: writeTSV},
+		} {
+			var f = f
+			t.Run(tw.desc+" in "+f.ext[1:], func(t *testing.T) {
+				var buf bytes.Buffer
+				require.NoError(t, f.write(&buf, testReport(t, tw.from, tw.to)))
+				assertGolden(t, "report"+tw.suffix+f.ext, buf.Bytes())
+			})
+		}
+	}
+
+	var tcases = []struct {
+		desc     string
+		write    formatFunc
+		table    string
+		expected string
+	}{
+		{
+			desc:     "csv quotes the fields with commas and quotes",
+			write:    writeCSV,
+			table:    "users",
+			expected: "rank,user,builds,failed,success_rate\n1,userA,5,3,0.4000\n2,\"user \"\"B\"\", <b>&|\",2,1,0.5000\n3,userC,1,0,1.0000\n",
+		},
+		{
+			desc:     "tsv quotes the fields with quotes",
+			write:    writeTSV,
+			table:    "top-users",
+			expected: "rank\tuser\tbuilds\tfailed\tsuccess_rate\n1\tuserA\t5\t3\t0.4000\n2\t\"user \"\"B\"\", <b>&|\"\t2\t1\t0.5000\n3\tuserC\t1\t0\t1.0000\n",
+		},
+		{
+			desc:     "single table without title",
+			write:    writeCSV,
+			table:    "exit-codes",
+			expected: "rank,exit_code,builds\n1,1,3\n2,2,1\n",
+		},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			var rep = testReport(t, testWindows[0].from, testWindows[0].to)
+			rep.Table = tc.table
+
+			var buf bytes.Buffer
+			require.NoError(t, tc.write(&buf, rep))
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+
+	t.Run("error: unknown table", func(t *testing.T) {
+		var rep = testReport(t, testWindows[0].from, testWindows[0].to)
+		rep.Table = "hosts"
+
+		var buf bytes.Buffer
+		assert.Error(t, writeCSV(&buf, rep))
+		assert.Equal(t, 0, buf.Len())
+	})
+}
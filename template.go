@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// templateData is the data which the user templates are rendered with; it's
+// documented in the README, so any change must keep it backwards compatible.
+type templateData struct {
+	// Builds are the stats of the time window.
+	Builds stats.Builds
+	// Users are all the users ranked by their number of builds.
+	Users []stats.UserBuilds
+	// ErrCodes are all the exit codes ranked by their number of failed builds.
+	ErrCodes []stats.ErrCodeBuilds
+	// GroupBy is the -group-by dimension name, empty when it isn't used.
+	GroupBy string
+	Groups  []stats.Group
+	// Outreach is true when -outreach is used.
+	Outreach bool
+	Findings []stats.Finding
+}
+
+// TopUsers returns the first n users of Users. It returns an error if n is
+// negative.
+func (d templateData) TopUsers(n int) ([]stats.UserBuilds, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("Invalid argument. TopUsers number cannot be negative, got %d", n)
+	}
+
+	if n < len(d.Users) {
+		return d.Users[:n], nil
+	}
+
+	return d.Users, nil
+}
+
+// TopErrCodes returns the first n exit codes of ErrCodes. It returns an error
+// if n is negative.
+func (d templateData) TopErrCodes(n int) ([]stats.ErrCodeBuilds, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("Invalid argument. TopErrCodes number cannot be negative, got %d", n)
+	}
+
+	if n < len(d.ErrCodes) {
+		return d.ErrCodes[:n], nil
+	}
+
+	return d.ErrCodes, nil
+}
+
+// templateFuncs are the helper functions available in the user templates.
+var templateFuncs = template.FuncMap{
+	"duration": templateDuration,
+	"percent":  templatePercent,
+	"bytes":    stats.FormatSize,
+}
+
+// templateDuration returns d rounded to seconds, or to milliseconds if it's
+// shorter than a second.
+func templateDuration(d time.Duration) string {
+	if d < time.Second && d > -time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+
+	return d.Round(time.Second).String()
+}
+
+// templatePercent returns the ratio r as a percentage with 2 decimals. r can be
+// a float32, float64 or a stats.Counts, whose success rate is used; it returns
+// "-" for the counts without builds.
+func templatePercent(r interface{}) (string, error) {
+	var v float64
+	switch t := r.(type) {
+	case float32:
+		v = float64(t)
+	case float64:
+		v = t
+	case stats.Counts:
+		if t.Num == 0 {
+			return "-", nil
+		}
+		v = float64(t.RateSuccess())
+	default:
+		return "", fmt.Errorf("percent: unsupported type %T", r)
+	}
+
+	return fmt.Sprintf("%.2f%%", v*100), nil
+}
+
+// newTemplateFormat returns a formatFunc which renders the report with the
+// text/template of the file of path.
+func newTemplateFormat(path string) (formatFunc, error) {
+	var tmpl, err = template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(w io.Writer, rep report) error {
+		return tmpl.Execute(w, templateData{
+			Builds:   rep.Builds,
+			Users:    rep.Builds.RankedUsers(),
+			ErrCodes: rep.Builds.RankedErrCodes(),
+			GroupBy:  rep.GroupBy,
+			Groups:   rep.Groups,
+			Outreach: rep.Outreach,
+			Findings: rep.Findings,
+		})
+	}, nil
+}
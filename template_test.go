@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTemplateFormat(t *testing.T) {
+	var format, err = newTemplateFormat(filepath.Join("testdata", "report.tmpl"))
+	require.NoError(t, err)
+
+	for i := range testWindows {
+		var tw = testWindows[i]
+		t.Run(tw.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, format(&buf, testReport(t, tw.from, tw.to)))
+			assertGolden(t, "report"+tw.suffix+".tmpl.txt", buf.Bytes())
+		})
+	}
+
+	t.Run("error: template file doesn't exist", func(t *testing.T) {
+		var _, err = newTemplateFormat(filepath.Join("testdata", "missing.tmpl"))
+		assert.Error(t, err)
+	})
+
+	t.Run("error: unsupported type of percent", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "percent.tmpl")
+		require.NoError(t, os.WriteFile(path, []byte(`{{percent .Builds.Num}}`), 0o600))
+
+		var format, err = newTemplateFormat(path)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		err = format(&buf, testReport(t, testWindows[0].from, testWindows[0].to))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "percent: unsupported type uint64")
+		}
+	})
+}
+
+func TestTemplateData_Top(t *testing.T) {
+	var d = templateData{
+		Users:    []stats.UserBuilds{{UserID: "a"}, {UserID: "b"}, {UserID: "c"}},
+		ErrCodes: []stats.ErrCodeBuilds{{ExitCode: 1}, {ExitCode: 2}},
+	}
+
+	var tcases = []struct {
+		n        int
+		users    int
+		errCodes int
+	}{
+		{n: 0, users: 0, errCodes: 0},
+		{n: 2, users: 2, errCodes: 2},
+		{n: 3, users: 3, errCodes: 2},
+		{n: 10, users: 3, errCodes: 2},
+	}
+
+	for _, tc := range tcases {
+		var users, err = d.TopUsers(tc.n)
+		require.NoError(t, err)
+		assert.Len(t, users, tc.users, "n: %d", tc.n)
+
+		codes, err := d.TopErrCodes(tc.n)
+		require.NoError(t, err)
+		assert.Len(t, codes, tc.errCodes, "n: %d", tc.n)
+	}
+
+	t.Run("error: negative number", func(t *testing.T) {
+		var _, err = d.TopUsers(-1)
+		assert.Error(t, err)
+
+		_, err = d.TopErrCodes(-1)
+		assert.Error(t, err)
+
+		var path = filepath.Join(t.TempDir(), "top.tmpl")
+		require.NoError(t, os.WriteFile(path, []byte(`{{range .TopUsers -1}}{{.UserID}}{{end}}`), 0o600))
+
+		format, err := newTemplateFormat(path)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		err = format(&buf, testReport(t, testWindows[0].from, testWindows[0].to))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "TopUsers number cannot be negative, got -1")
+		}
+	})
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	t.Run("duration", func(t *testing.T) {
+		assert.Equal(t, "1m30s", templateDuration(90*time.Second+400*time.Millisecond))
+		assert.Equal(t, "123ms", templateDuration(123456*time.Microsecond))
+		assert.Equal(t, "-2s", templateDuration(-1600*time.Millisecond))
+	})
+
+	t.Run("percent", func(t *testing.T) {
+		var tcases = []struct {
+			in       interface{}
+			expected string
+		}{
+			{in: float32(0.5), expected: "50.00%"},
+			{in: 0.12345, expected: "12.35%"},
+			{in: stats.Counts{Num: 4, NumFailed: 1}, expected: "75.00%"},
+			{in: stats.Counts{}, expected: "-"},
+		}
+
+		for _, tc := range tcases {
+			var p, err = templatePercent(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, p, "%#v", tc.in)
+		}
+
+		var _, err = templatePercent(5)
+		assert.Error(t, err)
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		var f = templateFuncs["bytes"].(func(int64) string)
+		assert.Equal(t, "1.5MB", f(1500000))
+		assert.Equal(t, "999B", f(999))
+	})
+}
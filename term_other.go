@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package main
+
+import "os"
+
+// ttyWidth returns 0 because the terminal width isn't available in this
+// platform, so termWidth falls back to the COLUMNS environment variable.
+func ttyWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+)
+
+// rangeNames are the names of the ranges accepted by the -range and -since
+// arguments.
+var rangeNames = []string{
+	"today", "yesterday", "this-week", "last-week", "this-month", "last-month", "this-year", "last-year",
+}
+
+// timeFormats describes the formats accepted by parseTime for the error
+// messages.
+const timeFormats = "RFC3339 (e.g. 2018-10-31T13:04:05.123-04:00), YYYY-MM-DD, YYYY-MM-DDTHH:MM, YYYY-MM-DDTHH:MM:SS, Unix epoch seconds or RFC822 (e.g. 31 Oct 18 13:04 EDT)"
+
+// localTimeLayouts are the layouts accepted by parseTime which don't have a
+// timezone.
+var localTimeLayouts = []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05"}
+
+// unixRegexp matches the Unix epoch seconds.
+var unixRegexp = regexp.MustCompile(`^-?\d+$`)
+
+// durationRegexp matches each number and unit of a duration of parseDuration.
+var durationRegexp = regexp.MustCompile(`(\d+(?:\.\d+)?)(ms|s|m|h|d|w)`)
+
+// windowFlags are the command line arguments which determine the time window.
+type windowFlags struct {
+	start  string
+	end    string
+	last   string
+	since  string
+	rng    string
+	now    string
+	tz     string
+	bounds string
+}
+
+func (wf *windowFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&wf.start, "s", "", "Start time & date of the time window (default any). Format must be one of: "+timeFormats)
+	fs.StringVar(&wf.end, "e", "", "End time & date of the time window (default current time). Same formats as -s.")
+	fs.StringVar(&wf.last, "last", "", "Time window which ends now and lasts the indicated duration; e.g. 15m, 12h, 1d, 2w, 1d12h")
+	fs.StringVar(&wf.since, "since", "", "Time window from the indicated moment until now; a duration ago (e.g. 2h), the start of a range ("+strings.Join(rangeNames, ", ")+") or a time & date with the same formats as -s")
+	fs.StringVar(&wf.rng, "range", "", "Time window of a named range: "+strings.Join(rangeNames, ", "))
+	fs.StringVar(&wf.now, "now", "", "Time & date which relative time windows are resolved against (default current time). Same formats as -s.")
+	fs.StringVar(&wf.bounds, "bounds", "", "Limits included in the time window: closed (both) or half-open (the start, but not the end). Default half-open with -range, so consecutive ranges don't share any build, and closed otherwise.")
+	fs.StringVar(&wf.tz, "tz", "", "IANA timezone name (e.g. America/New_York) of the times & dates without timezone, the hours and days of the timeline and groups, and the printed times & dates (default local)")
+}
+
+// location returns the location of the -tz argument, which is the local one
+// when it isn't set.
+func (wf windowFlags) location() (*time.Location, error) {
+	if wf.tz == "" {
+		return time.Local, nil
+	}
+
+	var loc, err = time.LoadLocation(wf.tz)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid timezone %q: %s", wf.tz, err.Error())
+	}
+
+	return loc, nil
+}
+
+// resolve returns the limits of the time window determined by the arguments and
+// which of them are included in it.
+func (wf windowFlags) resolve() (time.Time, time.Time, stats.Bounds, error) {
+	var from, to, err = wf.limits()
+	if err != nil {
+		return time.Time{}, time.Time{}, stats.BoundsClosed, err
+	}
+
+	var b = stats.BoundsClosed
+	if wf.rng != "" {
+		b = stats.BoundsHalfOpen
+	}
+
+	if wf.bounds != "" {
+		if b, err = stats.ParseBounds(wf.bounds); err != nil {
+			return time.Time{}, time.Time{}, stats.BoundsClosed, err
+		}
+	}
+
+	return from, to, b, nil
+}
+
+// limits returns the limits of the time window determined by the arguments.
+// -s and -e cannot be combined with the relative time windows and only one of
+// those can be used.
+func (wf windowFlags) limits() (time.Time, time.Time, error) {
+	var loc, err = wf.location()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	var now = time.Now().In(loc)
+	if wf.now != "" {
+		if now, err = parseTime(wf.now, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Invalid now time & date: %s", err.Error())
+		}
+	}
+
+	var relative = 0
+	for _, v := range []string{wf.last, wf.since, wf.rng} {
+		if v != "" {
+			relative++
+		}
+	}
+
+	if relative > 1 || (relative == 1 && (wf.start != "" || wf.end != "")) {
+		return time.Time{}, time.Time{}, errors.New("Only one of -last, -since, -range or -s & -e can be used")
+	}
+
+	switch {
+	case wf.last != "":
+		var d, err = parseDuration(wf.last)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+
+		return now.Add(-d), now, nil
+
+	case wf.since != "":
+		if from, _, err := namedRange(wf.since, now); err == nil {
+			return from, now, nil
+		}
+
+		if d, err := parseDuration(wf.since); err == nil {
+			return now.Add(-d), now, nil
+		}
+
+		var from, err = parseTime(wf.since, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Invalid -since value %q. It must be a duration, a range name or a time & date of one of these formats: %s", wf.since, timeFormats)
+		}
+
+		return from, now, nil
+
+	case wf.rng != "":
+		return namedRange(wf.rng, now)
+	}
+
+	var from, to = time.Time{}, now
+	if wf.start != "" {
+		if from, err = parseTime(wf.start, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Invalid start time & date: %s", err.Error())
+		}
+	}
+
+	if wf.end != "" {
+		if to, err = parseTime(wf.end, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Invalid end time & date: %s", err.Error())
+		}
+	}
+
+	return from, to, nil
+}
+
+// parseTime parses s, which must be of one of the timeFormats; the times
+// without timezone are in loc.
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	if unixRegexp.MatchString(s) {
+		var sec, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid Unix epoch seconds %q", s)
+		}
+
+		return time.Unix(sec, 0).In(loc), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+
+	for _, l := range localTimeLayouts {
+		if t, err := time.ParseInLocation(l, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	if t, err := time.Parse(time.RFC822, s); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("%q isn't of any of the accepted formats: %s", s, timeFormats)
+}
+
+// parseDuration parses a sequence of numbers followed by a unit, which, in
+// addition to the units of time.ParseDuration (except ns and us), can be d
+// (24 hours) and w (7 days).
+func parseDuration(s string) (time.Duration, error) {
+	var matches = durationRegexp.FindAllStringSubmatch(s, -1)
+	if len(matches) == 0 || len(strings.Join(durationRegexp.FindAllString(s, -1), "")) != len(s) {
+		return 0, fmt.Errorf("Invalid duration %q. It must be a sequence of numbers followed by a unit: ms, s, m, h, d, w", s)
+	}
+
+	var units = map[string]time.Duration{
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+	}
+
+	var d time.Duration
+	for _, m := range matches {
+		var v, err = strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, err
+		}
+
+		d += time.Duration(v * float64(units[m[2]]))
+	}
+
+	return d, nil
+}
+
+// namedRange returns the start of the range of name, which contains, or
+// precedes, now, and the start of the following range. Weeks start on Monday.
+func namedRange(name string, now time.Time) (time.Time, time.Time, error) {
+	var (
+		y, m, d = now.Date()
+		loc     = now.Location()
+		today   = time.Date(y, m, d, 0, 0, 0, 0, loc)
+		week    = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		month   = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+		year    = time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	)
+
+	switch name {
+	case "today":
+		return today, today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, nil
+	case "this-week":
+		return week, week.AddDate(0, 0, 7), nil
+	case "last-week":
+		return week.AddDate(0, 0, -7), week, nil
+	case "this-month":
+		return month, month.AddDate(0, 1, 0), nil
+	case "last-month":
+		return month.AddDate(0, -1, 0), month, nil
+	case "this-year":
+		return year, year.AddDate(1, 0, 0), nil
+	case "last-year":
+		return year.AddDate(-1, 0, 0), year, nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("Invalid range %q. Valid ones are: %s", name, strings.Join(rangeNames, ", "))
+	}
+}
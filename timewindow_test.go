@@ -0,0 +1,299 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // the tests don't depend on the timezones of the system
+
+	"github.com/ifraixedes/go-csv-reader-example/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	var loc, err = time.LoadLocation(name)
+	require.NoError(t, err)
+	return loc
+}
+
+func TestParseTime(t *testing.T) {
+	var ny = loadLocation(t, "America/New_York")
+
+	var tcases = []struct {
+		desc     string
+		in       string
+		expected time.Time
+		err      bool
+	}{
+		{
+			desc:     "RFC3339",
+			in:       "2018-10-31T13:04:05-04:00",
+			expected: time.Date(2018, 10, 31, 17, 4, 5, 0, time.UTC),
+		},
+		{
+			desc:     "RFC3339 with fractional seconds",
+			in:       "2018-10-31T13:04:05.123Z",
+			expected: time.Date(2018, 10, 31, 13, 4, 5, 123e6, time.UTC),
+		},
+		{
+			desc:     "date in the location",
+			in:       "2018-10-31",
+			expected: time.Date(2018, 10, 31, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:     "date and minutes in the location",
+			in:       "2018-10-31T13:04",
+			expected: time.Date(2018, 10, 31, 17, 4, 0, 0, time.UTC),
+		},
+		{
+			desc:     "date and seconds in the location after the DST change",
+			in:       "2018-11-05T13:04:05",
+			expected: time.Date(2018, 11, 5, 18, 4, 5, 0, time.UTC),
+		},
+		{
+			desc:     "Unix epoch seconds",
+			in:       "1541005445",
+			expected: time.Date(2018, 10, 31, 17, 4, 5, 0, time.UTC),
+		},
+		{
+			desc:     "negative Unix epoch seconds",
+			in:       "-60",
+			expected: time.Date(1969, 12, 31, 23, 59, 0, 0, time.UTC),
+		},
+		{
+			desc:     "RFC822",
+			in:       "31 Oct 18 13:04 UTC",
+			expected: time.Date(2018, 10, 31, 13, 4, 0, 0, time.UTC),
+		},
+		{desc: "error: date without day", in: "2018-10", err: true},
+		{desc: "error: out of range day", in: "2018-02-30", err: true},
+		{desc: "error: empty", in: "", err: true},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var tm, err = parseTime(tc.in, ny)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, tc.expected.Equal(tm), "expected %s, got %s", tc.expected, tm)
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	var tcases = []struct {
+		in       string
+		expected time.Duration
+		err      bool
+	}{
+		{in: "500ms", expected: 500 * time.Millisecond},
+		{in: "90m", expected: 90 * time.Minute},
+		{in: "1.5h", expected: 90 * time.Minute},
+		{in: "1d12h", expected: 36 * time.Hour},
+		{in: "2w", expected: 14 * 24 * time.Hour},
+		{in: "0.5d", expected: 12 * time.Hour},
+		{in: "", err: true},
+		{in: "5", err: true},
+		{in: "h", err: true},
+		{in: "1h30", err: true},
+		{in: "5us", err: true},
+		{in: "-1h", err: true},
+		{in: "1y", err: true},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			var d, err = parseDuration(tc.in)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+}
+
+func TestNamedRange(t *testing.T) {
+	var (
+		ny = loadLocation(t, "America/New_York")
+		// Wednesday.
+		now = time.Date(2018, 10, 31, 15, 0, 0, 0, ny)
+	)
+
+	var tcases = []struct {
+		desc string
+		name string
+		now  time.Time
+		from time.Time
+		to   time.Time
+	}{
+		{desc: "today", name: "today", now: now, from: time.Date(2018, 10, 31, 0, 0, 0, 0, ny), to: time.Date(2018, 11, 1, 0, 0, 0, 0, ny)},
+		{desc: "yesterday", name: "yesterday", now: now, from: time.Date(2018, 10, 30, 0, 0, 0, 0, ny), to: time.Date(2018, 10, 31, 0, 0, 0, 0, ny)},
+		{desc: "this week starts on Monday", name: "this-week", now: now, from: time.Date(2018, 10, 29, 0, 0, 0, 0, ny), to: time.Date(2018, 11, 5, 0, 0, 0, 0, ny)},
+		{desc: "last week", name: "last-week", now: now, from: time.Date(2018, 10, 22, 0, 0, 0, 0, ny), to: time.Date(2018, 10, 29, 0, 0, 0, 0, ny)},
+		{desc: "this week on Sunday", name: "this-week", now: time.Date(2018, 11, 4, 12, 0, 0, 0, ny), from: time.Date(2018, 10, 29, 0, 0, 0, 0, ny), to: time.Date(2018, 11, 5, 0, 0, 0, 0, ny)},
+		{desc: "this week on Monday", name: "this-week", now: time.Date(2018, 11, 5, 0, 0, 0, 0, ny), from: time.Date(2018, 11, 5, 0, 0, 0, 0, ny), to: time.Date(2018, 11, 12, 0, 0, 0, 0, ny)},
+		{desc: "this month", name: "this-month", now: now, from: time.Date(2018, 10, 1, 0, 0, 0, 0, ny), to: time.Date(2018, 11, 1, 0, 0, 0, 0, ny)},
+		{desc: "last month", name: "last-month", now: now, from: time.Date(2018, 9, 1, 0, 0, 0, 0, ny), to: time.Date(2018, 10, 1, 0, 0, 0, 0, ny)},
+		{desc: "last month in January", name: "last-month", now: time.Date(2019, 1, 15, 0, 0, 0, 0, ny), from: time.Date(2018, 12, 1, 0, 0, 0, 0, ny), to: time.Date(2019, 1, 1, 0, 0, 0, 0, ny)},
+		{desc: "this year", name: "this-year", now: now, from: time.Date(2018, 1, 1, 0, 0, 0, 0, ny), to: time.Date(2019, 1, 1, 0, 0, 0, 0, ny)},
+		{desc: "last year", name: "last-year", now: now, from: time.Date(2017, 1, 1, 0, 0, 0, 0, ny), to: time.Date(2018, 1, 1, 0, 0, 0, 0, ny)},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var from, to, err = namedRange(tc.name, tc.now)
+			require.NoError(t, err)
+			assert.True(t, tc.from.Equal(from), "from: expected %s, got %s", tc.from, from)
+			assert.True(t, tc.to.Equal(to), "to: expected %s, got %s", tc.to, to)
+		})
+	}
+
+	t.Run("days of DST changes", func(t *testing.T) {
+		// The day that the clocks are set forward lasts 23 hours and the one that
+		// they are set back 25.
+		var from, to, err = namedRange("today", time.Date(2018, 3, 11, 12, 0, 0, 0, ny))
+		require.NoError(t, err)
+		assert.Equal(t, 23*time.Hour, to.Sub(from))
+
+		from, to, err = namedRange("yesterday", time.Date(2018, 11, 5, 12, 0, 0, 0, ny))
+		require.NoError(t, err)
+		assert.Equal(t, 25*time.Hour, to.Sub(from))
+		assert.True(t, time.Date(2018, 11, 4, 4, 0, 0, 0, time.UTC).Equal(from))
+	})
+
+	t.Run("error: unknown range", func(t *testing.T) {
+		var _, _, err = namedRange("tomorrow", now)
+		assert.Error(t, err)
+	})
+}
+
+func TestWindowFlags_resolve(t *testing.T) {
+	var (
+		ny  = loadLocation(t, "America/New_York")
+		now = time.Date(2018, 10, 31, 15, 0, 0, 0, ny)
+	)
+
+	var tcases = []struct {
+		desc   string
+		wf     windowFlags
+		from   time.Time
+		to     time.Time
+		bounds stats.Bounds
+		err    bool
+	}{
+		{
+			desc: "start and end",
+			wf:   windowFlags{start: "2018-10-30", end: "2018-10-31T10:00"},
+			from: time.Date(2018, 10, 30, 0, 0, 0, 0, ny),
+			to:   time.Date(2018, 10, 31, 10, 0, 0, 0, ny),
+		},
+		{
+			desc: "defaults",
+			wf:   windowFlags{},
+			to:   now,
+		},
+		{
+			desc: "last",
+			wf:   windowFlags{last: "1d12h"},
+			from: now.Add(-36 * time.Hour),
+			to:   now,
+		},
+		{
+			desc: "since a duration",
+			wf:   windowFlags{since: "2h"},
+			from: now.Add(-2 * time.Hour),
+			to:   now,
+		},
+		{
+			desc: "since a range",
+			wf:   windowFlags{since: "this-month"},
+			from: time.Date(2018, 10, 1, 0, 0, 0, 0, ny),
+			to:   now,
+		},
+		{
+			desc: "since a time & date",
+			wf:   windowFlags{since: "2018-10-30T08:00"},
+			from: time.Date(2018, 10, 30, 8, 0, 0, 0, ny),
+			to:   now,
+		},
+		{
+			desc:   "range is half-open",
+			wf:     windowFlags{rng: "yesterday"},
+			from:   time.Date(2018, 10, 30, 0, 0, 0, 0, ny),
+			to:     time.Date(2018, 10, 31, 0, 0, 0, 0, ny),
+			bounds: stats.BoundsHalfOpen,
+		},
+		{
+			desc:   "range with closed bounds",
+			wf:     windowFlags{rng: "yesterday", bounds: "closed"},
+			from:   time.Date(2018, 10, 30, 0, 0, 0, 0, ny),
+			to:     time.Date(2018, 10, 31, 0, 0, 0, 0, ny),
+			bounds: stats.BoundsClosed,
+		},
+		{
+			desc:   "half-open bounds",
+			wf:     windowFlags{last: "2h", bounds: "half-open"},
+			from:   now.Add(-2 * time.Hour),
+			to:     now,
+			bounds: stats.BoundsHalfOpen,
+		},
+		{desc: "error: last and range", wf: windowFlags{last: "2h", rng: "today"}, err: true},
+		{desc: "error: since and last", wf: windowFlags{since: "2h", last: "2h"}, err: true},
+		{desc: "error: range and start", wf: windowFlags{rng: "today", start: "2018-10-30"}, err: true},
+		{desc: "error: since and end", wf: windowFlags{since: "2h", end: "2018-10-31"}, err: true},
+		{desc: "error: invalid since", wf: windowFlags{since: "a while"}, err: true},
+		{desc: "error: invalid last", wf: windowFlags{last: "2"}, err: true},
+		{desc: "error: invalid range", wf: windowFlags{rng: "tomorrow"}, err: true},
+		{desc: "error: invalid start", wf: windowFlags{start: "yesterday"}, err: true},
+		{desc: "error: invalid end", wf: windowFlags{end: "2018-13-01"}, err: true},
+		{desc: "error: invalid bounds", wf: windowFlags{bounds: "open"}, err: true},
+		{desc: "error: invalid timezone", wf: windowFlags{tz: "Mars/Olympus_Mons"}, err: true},
+	}
+
+	for i := range tcases {
+		var tc = tcases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var wf = tc.wf
+			wf.now = now.Format(time.RFC3339)
+			if wf.tz == "" {
+				wf.tz = "America/New_York"
+			}
+
+			var from, to, b, err = wf.resolve()
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, tc.from.Equal(from), "from: expected %s, got %s", tc.from, from)
+			assert.True(t, tc.to.Equal(to), "to: expected %s, got %s", tc.to, to)
+			assert.Equal(t, tc.bounds, b)
+		})
+	}
+
+	t.Run("error: invalid now", func(t *testing.T) {
+		var _, _, _, err = windowFlags{now: "soon"}.resolve()
+		assert.Error(t, err)
+	})
+}